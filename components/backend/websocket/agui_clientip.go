@@ -0,0 +1,184 @@
+// agui_clientip.go — trusted-proxy client IP + actor extraction for AG-UI
+// audit logging.
+//
+// HandleAGUIEvents/HandleAGUIRunProxy previously logged only session and
+// project, making it impossible to tell who issued a given request after
+// the fact. ClientIP mirrors the improved real-IP detection used by
+// nextcloud-spreed-signaling: X-Forwarded-For is only trusted from a
+// configured set of proxy CIDRs (AGUI_TRUSTED_PROXIES), and is walked
+// right-to-left so a spoofed left-most hop from the actual client can't
+// masquerade as a trusted proxy's own address.
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// aguiTrustedProxies holds the CIDRs of proxies allowed to set
+// X-Forwarded-For/X-Real-IP, parsed once from AGUI_TRUSTED_PROXIES (a
+// comma-separated list of CIDRs or bare IPs, e.g.
+// "10.0.0.0/8,192.168.1.1"). Empty/unset means no proxy is trusted, so
+// ClientIP falls back to the direct connection's RemoteAddr.
+var aguiTrustedProxies = parseTrustedProxies(os.Getenv("AGUI_TRUSTED_PROXIES"))
+
+// aguiTrustRealIPHeader gates the X-Real-IP fallback behind an explicit
+// opt-in (AGUI_TRUST_X_REAL_IP=true) — unlike X-Forwarded-For, it carries
+// no hop chain to validate, so trusting it unconditionally would let any
+// request reporting via a trusted proxy spoof an arbitrary IP with a
+// single header.
+var aguiTrustRealIPHeader = os.Getenv("AGUI_TRUST_X_REAL_IP") == "true"
+
+// parseTrustedProxies parses a comma-separated list of CIDRs or bare IPs
+// (bare IPs are treated as single-host /32 or /128 entries). Split out
+// from the env lookup so tests can exercise it directly.
+func parseTrustedProxies(raw string) []*net.IPNet {
+	if raw == "" {
+		return nil
+	}
+	var nets []*net.IPNet
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil {
+				if ip.To4() != nil {
+					part += "/32"
+				} else {
+					part += "/128"
+				}
+			}
+		}
+		_, n, err := net.ParseCIDR(part)
+		if err != nil {
+			log.Printf("AGUI ClientIP: ignoring invalid AGUI_TRUSTED_PROXIES entry %q: %v", part, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range aguiTrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOnly strips a port (and IPv6 brackets) from an address of the form
+// "host:port", "[::1]:port" or a bare "[::1]"/"1.2.3.4" literal.
+func hostOnly(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// ClientIP returns the best-effort real client IP for r: if the direct
+// peer (RemoteAddr) is a trusted proxy, X-Forwarded-For is walked
+// right-to-left, skipping any hop that is itself a trusted proxy, and the
+// first untrusted hop is returned. If every hop is trusted (or XFF is
+// absent) and AGUI_TRUST_X_REAL_IP is enabled, X-Real-IP is used. In all
+// other cases — including when the direct peer is NOT a trusted proxy,
+// meaning any forwarding headers could have been forged by the client
+// itself — RemoteAddr is returned as-is.
+func ClientIP(r *http.Request) string {
+	remoteIP := hostOnly(r.RemoteAddr)
+
+	if !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := hostOnly(hops[i])
+			if hop == "" {
+				continue
+			}
+			if !isTrustedProxy(hop) {
+				return hop
+			}
+		}
+		// Every hop, including the presumed originating client, matched a
+		// trusted proxy CIDR — nothing left to peel. Fall through.
+	}
+
+	if aguiTrustRealIPHeader {
+		if rip := hostOnly(r.Header.Get("X-Real-IP")); rip != "" {
+			return rip
+		}
+	}
+
+	return remoteIP
+}
+
+// actorFromRequest extracts a best-effort identifier for the authenticated
+// user, for audit logging only. GetK8sClientsForRequest (handlers package)
+// has already verified the bearer token against the API server via
+// TokenReview by the time a handler runs; this just reads the "sub" claim
+// back out of the same token's payload without re-verifying it, purely to
+// label log lines and persisted events. If the header is missing or
+// malformed, it returns "".
+func actorFromRequest(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return ""
+	}
+	token := strings.TrimPrefix(auth, prefix)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Sub
+}
+
+// aguiActor bundles the client IP and authenticated user for a single
+// request, for log lines and the "actor" field persisted on RUN_STARTED
+// events (see startAGUIRun).
+type aguiActor struct {
+	IP   string
+	User string
+}
+
+func actorFromHTTPRequest(r *http.Request) aguiActor {
+	return aguiActor{IP: ClientIP(r), User: actorFromRequest(r)}
+}
+
+// String renders as "user@ip", or just "ip" if no user claim was found.
+func (a aguiActor) String() string {
+	if a.User == "" {
+		return a.IP
+	}
+	return a.User + "@" + a.IP
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withTrustedProxies(t *testing.T, cidrs string, fn func()) {
+	t.Helper()
+	prev := aguiTrustedProxies
+	aguiTrustedProxies = parseTrustedProxies(cidrs)
+	defer func() { aguiTrustedProxies = prev }()
+	fn()
+}
+
+func TestClientIP_UntrustedDirectClientSpoofsHeader(t *testing.T) {
+	withTrustedProxies(t, "", func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "203.0.113.9:54321"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+		got := ClientIP(r)
+		if got != "203.0.113.9" {
+			t.Fatalf("expected spoofed X-Forwarded-For to be ignored from an untrusted peer, got %q", got)
+		}
+	})
+}
+
+func TestClientIP_ChainedTrustedProxies(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8", func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.5:443"
+		r.Header.Set("X-Forwarded-For", "198.51.100.23, 10.0.0.1, 10.0.0.2")
+
+		got := ClientIP(r)
+		if got != "198.51.100.23" {
+			t.Fatalf("expected real client IP after skipping trusted hops, got %q", got)
+		}
+	})
+}
+
+func TestClientIP_IPv6Bracketed(t *testing.T) {
+	withTrustedProxies(t, "", func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "[2001:db8::1]:54321"
+
+		got := ClientIP(r)
+		if got != "2001:db8::1" {
+			t.Fatalf("expected bracketed IPv6 literal stripped of brackets/port, got %q", got)
+		}
+	})
+}
+
+func TestClientIP_AllHopsTrustedFallsBackToRemoteAddr(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8", func() {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.5:443"
+		r.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+
+		got := ClientIP(r)
+		if got != "10.0.0.5" {
+			t.Fatalf("expected RemoteAddr fallback when every XFF hop is trusted, got %q", got)
+		}
+	})
+}
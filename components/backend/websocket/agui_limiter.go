@@ -0,0 +1,170 @@
+// agui_limiter.go — in-flight request limiting for AG-UI handlers.
+//
+// Modeled on upstream Kubernetes' generic apiserver MaxRequestsInFlight
+// filter: two independent token buckets (short requests vs long-running
+// connections), classified by route, so a burst of cheap calls can't
+// starve SSE/WebSocket connections and vice versa. Short handlers that
+// exceed their cap get 429 with Retry-After; long-running handlers get
+// 503. A per-request timeout wraps short handlers so a stuck runner
+// can't pin a goroutine (and an in-flight slot) indefinitely.
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// aguiInFlightLimiter is a simple counting semaphore: acquire fails
+// immediately (non-blocking) once max in-flight slots are taken, rather
+// than queuing, so callers can respond with 429/503 instead of stalling.
+type aguiInFlightLimiter struct {
+	max     int64
+	current int64
+}
+
+func newAGUIInFlightLimiter(max int64) *aguiInFlightLimiter {
+	return &aguiInFlightLimiter{max: max}
+}
+
+// acquire reserves a slot, returning false if the limiter is already at
+// capacity. max <= 0 disables the limit (always succeeds).
+func (l *aguiInFlightLimiter) acquire() bool {
+	if l.max <= 0 {
+		atomic.AddInt64(&l.current, 1)
+		return true
+	}
+	for {
+		cur := atomic.LoadInt64(&l.current)
+		if cur >= l.max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&l.current, cur, cur+1) {
+			return true
+		}
+	}
+}
+
+func (l *aguiInFlightLimiter) release() {
+	atomic.AddInt64(&l.current, -1)
+}
+
+func (l *aguiInFlightLimiter) inFlight() int64 {
+	return atomic.LoadInt64(&l.current)
+}
+
+// Short handlers (POST /agui/run, /agui/interrupt, /agui/feedback,
+// GET /capabilities, /mcp/status) and long-running ones (GET /agui/events,
+// the WebSocket upgrade) are rate-limited independently — a burst of
+// short calls shouldn't be able to block a session's event stream, and a
+// pile-up of SSE/WebSocket connections shouldn't 429 ordinary API calls.
+var (
+	aguiShortLimiter = newAGUIInFlightLimiter(intFromEnv("AGUI_MAX_INFLIGHT", 200))
+	aguiLongLimiter  = newAGUIInFlightLimiter(intFromEnv("AGUI_MAX_INFLIGHT_LONG", 100))
+)
+
+// aguiShortRequestTimeout bounds how long a short handler's runner call
+// may run before c.Request's context is canceled. 504 is returned only
+// when the handler has written no response by then; the wrapped
+// handlers write their own response when the runner call fails (502 or
+// the runner's status for interrupt, 202 for feedback, a 200 fallback
+// body for capabilities and MCP status), and that is what the client
+// gets instead. Set via AGUI_SHORT_TIMEOUT_SECONDS; <= 0 disables the
+// bound.
+var aguiShortRequestTimeout = time.Duration(intFromEnv("AGUI_SHORT_TIMEOUT_SECONDS", 20)) * time.Second
+
+func intFromEnv(key string, def int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// aguiInFlightCounters exposes current in-flight counts for the
+// debug/metrics endpoint.
+type aguiInFlightCounters struct {
+	ShortInFlight int64 `json:"shortInFlight"`
+	ShortMax      int64 `json:"shortMax"`
+	LongInFlight  int64 `json:"longInFlight"`
+	LongMax       int64 `json:"longMax"`
+}
+
+func aguiInFlightSnapshot() aguiInFlightCounters {
+	return aguiInFlightCounters{
+		ShortInFlight: aguiShortLimiter.inFlight(),
+		ShortMax:      aguiShortLimiter.max,
+		LongInFlight:  aguiLongLimiter.inFlight(),
+		LongMax:       aguiLongLimiter.max,
+	}
+}
+
+// WithAGUIShortLimit wraps a short AG-UI handler (POST /agui/run,
+// /agui/interrupt, /agui/feedback, GET /capabilities, /mcp/status) with
+// the short-request in-flight limiter and a per-request timeout.
+//
+// The timeout is applied by replacing c.Request's context with one that
+// carries a deadline; the handler itself runs synchronously (not in a
+// goroutine) to avoid two goroutines writing to the same gin.Context.
+// It's up to the handler's own runner calls to be built with
+// http.NewRequestWithContext(c.Request.Context(), ...) so a stuck runner
+// actually observes the cancellation — see sendInterruptToRunner,
+// sendFeedbackToRunner, HandleCapabilities and HandleMCPStatus.
+func WithAGUIShortLimit(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordRequestStart()
+		defer recordRequestEnd()
+
+		if !aguiShortLimiter.acquire() {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "AG-UI request limit reached, retry shortly"})
+			c.Abort()
+			return
+		}
+		defer aguiShortLimiter.release()
+
+		if aguiShortRequestTimeout <= 0 {
+			handler(c)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), aguiShortRequestTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		handler(c)
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "AG-UI request timed out"})
+			c.Abort()
+		}
+	}
+}
+
+// WithAGUILongLimit wraps a long-running AG-UI handler (GET /agui/events,
+// the WebSocket upgrade) with the long-running in-flight limiter. There
+// is no timeout here — these connections are expected to live for the
+// duration of a run (or longer, for idle SSE/WebSocket tailing).
+func WithAGUILongLimit(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordRequestStart()
+		defer recordRequestEnd()
+
+		if !aguiLongLimiter.acquire() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AG-UI is at capacity for long-running connections, retry shortly"})
+			c.Abort()
+			return
+		}
+		defer aguiLongLimiter.release()
+		handler(c)
+	}
+}
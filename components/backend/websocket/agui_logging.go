@@ -0,0 +1,28 @@
+// agui_logging.go — structured logging + request-ID correlation for the
+// AG-UI package.
+//
+// Every AG-UI handler generates a request ID at entry and threads it
+// through proxyRunnerStream, persistStreamedEvent,
+// publishAndPersistErrorEvents and emitHiddenMessageMetadata, so a single
+// log line identifies which client request produced it. It's echoed back
+// to the client via the X-Request-Id response header and set as an
+// outbound X-Request-Id header on every runner call, so the runner can
+// log the same ID — the same correlation-ID pattern Arvados' ws router
+// uses, and a prerequisite for wiring this package into OpenTelemetry
+// tracing later.
+package websocket
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+var aguiLog = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// newRequestID generates a per-request correlation ID, shortened the same
+// way truncID shortens run/thread IDs for log readability.
+func newRequestID() string {
+	return truncID(uuid.New().String())
+}
@@ -0,0 +1,278 @@
+// agui_metrics.go — instrumentation for the AG-UI event pipeline.
+//
+// Follows the pattern of Arvados' ws router.DebugStatus: a handful of
+// atomic counters plus per-session gauges, exposed two ways — a plain
+// JSON snapshot at GET /agui/debug/status (for a human or a quick curl
+// during an incident) and Prometheus metrics at /metrics (for the usual
+// scrape-and-alert pipeline). subscribeLive/cleanup, persistEvent and
+// proxyRunnerStream are the instrumentation points; nothing here changes
+// their behavior.
+package websocket
+
+import (
+	"ambient-code-backend/handlers"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	authv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// aguiMetrics holds the process-wide atomic counters backing both the
+// debug/status JSON and the Prometheus counters/gauges below.
+var aguiMetrics = struct {
+	reqsReceived    int64
+	reqsActive      int64
+	bytesStreamed   int64
+	eventsPersisted int64
+	eventsDropped   int64
+}{}
+
+// aguiSessionStats tracks per-session state that isn't already held
+// elsewhere: current SSE/WebSocket subscriber count and the last time
+// an event was persisted. Keyed by sessionName, same convention as
+// liveBroadcasts and writeMutexes.
+type aguiSessionStats struct {
+	subscribers   int64
+	lastEventUnix int64 // unix millis, 0 if no event yet
+}
+
+var aguiSessions sync.Map // sessionName → *aguiSessionStats
+
+func getSessionStats(sessionName string) *aguiSessionStats {
+	val, _ := aguiSessions.LoadOrStore(sessionName, &aguiSessionStats{})
+	return val.(*aguiSessionStats)
+}
+
+// aguiSessions entries are evicted after aguiSessionEvictAge of
+// inactivity (no subscribers and no event persisted in that window) to
+// prevent unbounded sync.Map growth on long-running backends — same
+// pattern as writeMutexes in agui_store.go.
+const aguiSessionEvictAge = 30 * time.Minute
+
+func init() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		for range ticker.C {
+			evictStaleAguiSessions()
+		}
+	}()
+}
+
+// evictStaleAguiSessions removes session stats for sessions with no
+// current subscribers and no event persisted within aguiSessionEvictAge.
+func evictStaleAguiSessions() {
+	threshold := time.Now().Add(-aguiSessionEvictAge).UnixMilli()
+	aguiSessions.Range(func(key, val interface{}) bool {
+		st := val.(*aguiSessionStats)
+		if atomic.LoadInt64(&st.subscribers) == 0 && atomic.LoadInt64(&st.lastEventUnix) < threshold {
+			aguiSessions.Delete(key)
+		}
+		return true
+	})
+}
+
+// recordRequestStart/recordRequestEnd instrument every AG-UI handler via
+// WithAGUIShortLimit/WithAGUILongLimit (see agui_limiter.go), so
+// ReqsReceived and ReqsActive cover all AG-UI traffic without each
+// handler needing its own bookkeeping.
+func recordRequestStart() {
+	atomic.AddInt64(&aguiMetrics.reqsReceived, 1)
+	atomic.AddInt64(&aguiMetrics.reqsActive, 1)
+	aguiReqsReceivedTotal.Inc()
+	aguiReqsActive.Inc()
+}
+
+func recordRequestEnd() {
+	atomic.AddInt64(&aguiMetrics.reqsActive, -1)
+	aguiReqsActive.Dec()
+}
+
+// recordSubscriberJoin/Leave instrument subscribeLive/cleanup.
+func recordSubscriberJoin(sessionName string) {
+	st := getSessionStats(sessionName)
+	n := atomic.AddInt64(&st.subscribers, 1)
+	aguiSubscribersGauge.WithLabelValues(sessionName).Set(float64(n))
+}
+
+func recordSubscriberLeave(sessionName string) {
+	st := getSessionStats(sessionName)
+	n := atomic.AddInt64(&st.subscribers, -1)
+	if n <= 0 {
+		// Mirror the /debug/status pruning above: once a session has no
+		// subscribers left, drop its series instead of leaving it at 0
+		// forever — otherwise every session that ever had a subscriber
+		// accumulates a permanent label value for the life of the process.
+		aguiSubscribersGauge.DeleteLabelValues(sessionName)
+		return
+	}
+	aguiSubscribersGauge.WithLabelValues(sessionName).Set(float64(n))
+}
+
+// recordEventPersisted instruments persistEvent.
+func recordEventPersisted(sessionName string) {
+	atomic.AddInt64(&aguiMetrics.eventsPersisted, 1)
+	aguiEventsPersistedTotal.Inc()
+	st := getSessionStats(sessionName)
+	atomic.StoreInt64(&st.lastEventUnix, time.Now().UnixMilli())
+}
+
+// recordEventDropped instruments publishLine's slow-subscriber drop path.
+func recordEventDropped() {
+	atomic.AddInt64(&aguiMetrics.eventsDropped, 1)
+	aguiEventsDroppedTotal.Inc()
+}
+
+// recordBytesStreamed instruments proxyRunnerStream's per-line reads
+// from the runner's SSE response.
+func recordBytesStreamed(n int) {
+	atomic.AddInt64(&aguiMetrics.bytesStreamed, int64(n))
+	aguiBytesStreamedTotal.Add(float64(n))
+}
+
+// ─── Prometheus metrics ──────────────────────────────────────────────
+
+var (
+	aguiReqsReceivedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agui_requests_received_total",
+		Help: "Total AG-UI HTTP/WebSocket requests received.",
+	})
+	aguiReqsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agui_requests_active",
+		Help: "AG-UI HTTP/WebSocket requests currently in flight.",
+	})
+	aguiEventsPersistedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agui_events_persisted_total",
+		Help: "Total AG-UI events appended to the event log.",
+	})
+	aguiEventsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agui_events_dropped_total",
+		Help: "Total AG-UI events dropped because a live subscriber's channel was full.",
+	})
+	aguiBytesStreamedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agui_bytes_streamed_total",
+		Help: "Total bytes read from runner SSE responses in proxyRunnerStream.",
+	})
+	aguiSubscribersGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agui_subscribers",
+		Help: "Current live event subscribers (SSE + WebSocket) per session.",
+	}, []string{"session"})
+	aguiRunnerTTFBSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "agui_runner_ttfb_seconds",
+		Help:    "Time from connectToRunner's dial to the runner's first response byte.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		aguiReqsReceivedTotal,
+		aguiReqsActive,
+		aguiEventsPersistedTotal,
+		aguiEventsDroppedTotal,
+		aguiBytesStreamedTotal,
+		aguiSubscribersGauge,
+		aguiRunnerTTFBSeconds,
+	)
+}
+
+// HandleAGUIMetrics serves Prometheus metrics for the whole process (not
+// just AG-UI) at GET /metrics, via the default registerer used above.
+var HandleAGUIMetrics = gin.WrapH(promhttp.Handler())
+
+// ─── GET /agui/debug/status ──────────────────────────────────────────
+
+// aguiSessionStatus is one session's entry in the debug/status response.
+type aguiSessionStatus struct {
+	Session       string `json:"session"`
+	Subscribers   int64  `json:"subscribers"`
+	LastEventUnix int64  `json:"lastEventUnixMillis,omitempty"`
+}
+
+// aguiDebugStatus is the full GET /agui/debug/status response body.
+type aguiDebugStatus struct {
+	ReqsReceived    int64                `json:"reqsReceived"`
+	ReqsActive      int64                `json:"reqsActive"`
+	BytesStreamed   int64                `json:"bytesStreamed"`
+	EventsPersisted int64                `json:"eventsPersisted"`
+	EventsDropped   int64                `json:"eventsDropped"`
+	InFlight        aguiInFlightCounters `json:"inFlight"`
+	Sessions        []aguiSessionStatus  `json:"sessions"`
+}
+
+// HandleAGUIDebugStatus returns a JSON snapshot of AG-UI instrumentation
+// for diagnosing the race conditions, dropped-client, and "runner not
+// ready" symptoms noted elsewhere in this package. Admin-only: unlike
+// the other AG-UI handlers, access is checked against a cluster-scoped
+// resource rather than a specific session, since this exposes every
+// session's subscriber counts.
+func HandleAGUIDebugStatus(c *gin.Context) {
+	reqK8s, _ := handlers.GetK8sClientsForRequest(c)
+	if reqK8s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing token"})
+		c.Abort()
+		return
+	}
+	if !checkClusterAccess(reqK8s, "get") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		c.Abort()
+		return
+	}
+
+	status := aguiDebugStatus{
+		ReqsReceived:    atomic.LoadInt64(&aguiMetrics.reqsReceived),
+		ReqsActive:      atomic.LoadInt64(&aguiMetrics.reqsActive),
+		BytesStreamed:   atomic.LoadInt64(&aguiMetrics.bytesStreamed),
+		EventsPersisted: atomic.LoadInt64(&aguiMetrics.eventsPersisted),
+		EventsDropped:   atomic.LoadInt64(&aguiMetrics.eventsDropped),
+		InFlight:        aguiInFlightSnapshot(),
+	}
+	aguiSessions.Range(func(key, val interface{}) bool {
+		sessionName := key.(string)
+		st := val.(*aguiSessionStats)
+		subs := atomic.LoadInt64(&st.subscribers)
+		last := atomic.LoadInt64(&st.lastEventUnix)
+		if subs == 0 && last == 0 {
+			return true // nothing to report — skip a stale/cleaned-up entry
+		}
+		status.Sessions = append(status.Sessions, aguiSessionStatus{
+			Session:       sessionName,
+			Subscribers:   subs,
+			LastEventUnix: last,
+		})
+		return true
+	})
+
+	c.JSON(http.StatusOK, status)
+}
+
+// checkClusterAccess performs a SelfSubjectAccessReview for the given
+// verb against the AgenticSession resource cluster-wide (no namespace),
+// distinguishing "can debug the whole deployment" from checkAccess's
+// "can access this one session".
+func checkClusterAccess(reqK8s kubernetes.Interface, verb string) bool {
+	ssar := &authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Group:    "vteam.ambient-code",
+				Resource: "agenticsessions",
+				Verb:     verb,
+			},
+		},
+	}
+	res, err := reqK8s.AuthorizationV1().SelfSubjectAccessReviews().Create(
+		context.Background(), ssar, metav1.CreateOptions{},
+	)
+	if err != nil || !res.Status.Allowed {
+		return false
+	}
+	return true
+}
@@ -20,7 +20,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -41,7 +40,11 @@ import (
 //
 //	POST /agui/run  → starts a run, returns JSON metadata immediately
 //	GET  /agui/events → SSE stream of all thread events (past + future)
-func HandleAGUIEvents(c *gin.Context) {
+//
+// Wrapped with the long-running in-flight limiter — see agui_limiter.go.
+var HandleAGUIEvents = WithAGUILongLimit(handleAGUIEventsImpl)
+
+func handleAGUIEventsImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -58,7 +61,18 @@ func HandleAGUIEvents(c *gin.Context) {
 		return
 	}
 
-	log.Printf("AGUI Events: client connected for %s/%s", projectName, sessionName)
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+	aguiLog.Info("AGUI Events: client connected", "reqId", reqID, "session", sessionName, "actor", actorFromHTTPRequest(c.Request).String())
+
+	// Standard SSE clients (EventSource) resend the id of the last event
+	// they saw as Last-Event-ID on reconnect — honor it so we only
+	// replay what the client actually missed. EventSource polyfills that
+	// can't set arbitrary headers fall back to a ?lastEventId= query param.
+	lastEventID := c.Request.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
 
 	// ── SSE response headers ─────────────────────────────────────
 	c.Header("Content-Type", "text/event-stream")
@@ -73,29 +87,71 @@ func HandleAGUIEvents(c *gin.Context) {
 	liveCh, cleanup := subscribeLive(sessionName)
 	defer cleanup()
 
-	events := loadEvents(sessionName)
-
-	if len(events) > 0 {
-		// Check if the last run is finished.
-		runFinished := false
-		if last := events[len(events)-1]; last != nil {
-			if t, _ := last["type"].(string); t == types.EventTypeRunFinished {
-				runFinished = true
-			}
+	// Peek at the last persisted event (bounded memory — does not load
+	// the full session history) to decide which replay mode to use.
+	runFinished := false
+	if last := lastEvent(sessionName); last != nil {
+		if t, _ := last["type"].(string); t == types.EventTypeRunFinished {
+			runFinished = true
 		}
 
 		if runFinished {
-			// Finished runs get compacted replay (fast, small).
-			compacted := compactStreamingEvents(events)
-			log.Printf("AGUI Events: %d raw → %d compacted events for %s (finished)", len(events), len(compacted), sessionName)
-			for _, evt := range compacted {
-				writeSSEEvent(c.Writer, evt)
+			// Finished runs get a streaming compacted replay: events are
+			// pulled and compacted one at a time, so memory is bounded by
+			// currently-open TEXT_MESSAGE/TOOL_CALL sequences, not by
+			// total session history. The ID-aware variant still honors
+			// Last-Event-ID: each compacted group carries forward the
+			// cursor of the last raw event it subsumes, so resume works
+			// whether or not the run has been compacted since the
+			// client's last visit. If the client's cursor is never
+			// matched (e.g. the session's history no longer contains it),
+			// buffer what was skipped and replay it all at the end rather
+			// than silently sending nothing — same fallback resumeIndex
+			// already gives the active-run branch below.
+			aguiLog.Info("AGUI Events: streaming compacted replay (finished)", "reqId", reqID, "session", sessionName)
+			resumeCursor, hasResume := parseEventCursor(lastEventID)
+			passedResume := !hasResume
+			var skipped []pendingReplayCursorEvent
+			next, closeIter := loadEventsIterWithIDs(sessionName)
+			compactStreamingEventsIterWithIDs(next, func(evt map[string]interface{}, cursor eventCursor) {
+				if !passedResume {
+					if cursor == resumeCursor {
+						passedResume = true
+					} else {
+						skipped = append(skipped, pendingReplayCursorEvent{evt: evt, cursor: cursor})
+					}
+					return
+				}
+				writeSSEEventWithID(c.Writer, evt, cursor.String())
+			})
+			closeIter()
+			if !passedResume {
+				aguiLog.Info("AGUI Events: resume cursor not found, falling back to full replay", "reqId", reqID, "session", sessionName)
+				for _, p := range skipped {
+					writeSSEEventWithID(c.Writer, p.evt, p.cursor.String())
+				}
 			}
 		} else {
 			// Active run — send raw events to preserve streaming structure.
-			log.Printf("AGUI Events: replaying %d raw events for %s (running)", len(events), sessionName)
-			for _, evt := range events {
-				writeSSEEvent(c.Writer, evt)
+			events, ids := loadEventsWithIDs(sessionName)
+
+			startIdx := resumeIndex(lastEventID, ids)
+			if startIdx > 0 {
+				// Re-send starts for any sequence still open at the cursor
+				// so the client can attribute the deltas that follow —
+				// it never saw the original TEXT_MESSAGE_START/TOOL_CALL_START.
+				for _, evt := range openSequencesBeforeCursor(events[:startIdx]) {
+					writeSSEEvent(c.Writer, evt)
+				}
+			}
+
+			aguiLog.Info("AGUI Events: replaying raw events (running)", "reqId", reqID, "session", sessionName, "count", len(events)-startIdx, "resumed", startIdx > 0)
+			for i := startIdx; i < len(events); i++ {
+				id := ""
+				if i < len(ids) {
+					id = ids[i].String()
+				}
+				writeSSEEventWithID(c.Writer, events[i], id)
 			}
 		}
 		c.Writer.Flush()
@@ -115,7 +171,7 @@ func HandleAGUIEvents(c *gin.Context) {
 	for {
 		select {
 		case <-clientGone:
-			log.Printf("AGUI Events: client disconnected for %s", sessionName)
+			aguiLog.Info("AGUI Events: client disconnected", "reqId", reqID, "session", sessionName)
 			return
 		case line, ok := <-liveCh:
 			if !ok {
@@ -135,7 +191,12 @@ func HandleAGUIEvents(c *gin.Context) {
 // runner pod in a background goroutine, and returns JSON metadata
 // immediately.  Events are persisted and broadcast to GET /agui/events
 // subscribers via the live broadcast pipe.
-func HandleAGUIRunProxy(c *gin.Context) {
+//
+// Wrapped with the short-request in-flight limiter and timeout — see
+// agui_limiter.go.
+var HandleAGUIRunProxy = WithAGUIShortLimit(handleAGUIRunProxyImpl)
+
+func handleAGUIRunProxyImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -152,20 +213,47 @@ func HandleAGUIRunProxy(c *gin.Context) {
 		return
 	}
 
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+
 	// Parse input (messages are json.RawMessage pass-through)
 	var input types.RunAgentInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		log.Printf("AGUI Proxy: Failed to parse input: %v", err)
+		aguiLog.Error("AGUI Proxy: failed to parse input", "reqId", reqID, "err", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid input: %v", err)})
 		return
 	}
 
-	// Generate or use provided IDs
-	threadID := input.ThreadID
+	threadID, runID, err := startAGUIRun(projectName, sessionName, input, actorFromHTTPRequest(c.Request), reqID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return metadata immediately — events arrive via GET /agui/events
+	c.JSON(http.StatusOK, gin.H{
+		"runId":    runID,
+		"threadId": threadID,
+	})
+}
+
+// startAGUIRun assigns thread/run IDs, emits hidden-message metadata
+// and display-name generation as needed, and kicks off
+// proxyRunnerStream in the background. Shared by every client-facing
+// transport (HTTP POST /agui/run, and the WebSocket "run" frame) so
+// run semantics stay identical regardless of how the client connects.
+//
+// actor identifies who issued the request (see agui_clientip.go) — it is
+// logged here and attached to the RUN_STARTED event so the event log
+// itself is auditable, not just the server logs. reqID correlates every
+// log line and runner call this run produces back to the client request
+// that started it — see agui_logging.go.
+func startAGUIRun(projectName, sessionName string, input types.RunAgentInput, actor aguiActor, reqID string) (threadID, runID string, err error) {
+	threadID = input.ThreadID
 	if threadID == "" {
 		threadID = sessionName
 	}
-	runID := input.RunID
+	runID = input.RunID
 	if runID == "" {
 		runID = uuid.New().String()
 	}
@@ -178,7 +266,7 @@ func HandleAGUIRunProxy(c *gin.Context) {
 		_ = json.Unmarshal(input.Messages, &rawMessages)
 	}
 
-	log.Printf("AGUI Proxy: run=%s session=%s/%s msgs=%d", truncID(runID), projectName, sessionName, len(rawMessages))
+	aguiLog.Info("AGUI Proxy: starting run", "reqId", reqID, "runId", truncID(runID), "session", sessionName, "project", projectName, "msgs", len(rawMessages), "actor", actor.String())
 
 	// Parse messages for display name generation and hidden metadata
 	var minimalMsgs []types.Message
@@ -194,51 +282,48 @@ func HandleAGUIRunProxy(c *gin.Context) {
 
 	// Emit message_metadata RAW events for hidden messages (e.g. auto-sent
 	// workflow prompts).  These must be persisted and broadcast BEFORE the
-	// runner starts emitting events so GET /agui/events subscribers hide
-	// the messages before they arrive via TEXT_MESSAGE_* events.
+	// runner starts emitting events so subscribers hide the messages
+	// before they arrive via TEXT_MESSAGE_* events.
 	for _, msg := range minimalMsgs {
 		if isMessageHidden(msg.Metadata) {
-			emitHiddenMessageMetadata(sessionName, runID, threadID, msg.ID)
+			emitHiddenMessageMetadata(sessionName, runID, threadID, msg.ID, reqID)
 		}
 	}
 
-	// ── Forward to runner in background, return JSON immediately ──
-	bodyBytes, err := json.Marshal(input)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to serialize input"})
-		return
+	bodyBytes, marshalErr := json.Marshal(input)
+	if marshalErr != nil {
+		return "", "", fmt.Errorf("failed to serialize input: %w", marshalErr)
 	}
 
 	runnerURL := getRunnerEndpoint(projectName, sessionName)
 
-	// Start background goroutine to proxy runner SSE → persist + broadcast
-	go proxyRunnerStream(runnerURL, bodyBytes, sessionName, runID, threadID)
+	// Start background goroutine to proxy runner SSE → persist + broadcast.
+	// proxyRunnerStream publishes via publishLine/subscribeLive, which is
+	// keyed by sessionName, not by transport — so SSE and WebSocket
+	// subscribers of the same session see identical events.
+	go proxyRunnerStream(runnerURL, bodyBytes, sessionName, runID, threadID, actor, reqID)
 
-	// Return metadata immediately — events arrive via GET /agui/events
-	c.JSON(http.StatusOK, gin.H{
-		"runId":    runID,
-		"threadId": threadID,
-	})
+	return threadID, runID, nil
 }
 
 // proxyRunnerStream connects to the runner's SSE endpoint, reads events,
 // persists them, and publishes them to the live broadcast pipe.  Runs in
 // a background goroutine so the POST /agui/run handler can return immediately.
-func proxyRunnerStream(runnerURL string, bodyBytes []byte, sessionName, runID, threadID string) {
-	log.Printf("AGUI Proxy: connecting to runner at %s", runnerURL)
-	resp, err := connectToRunner(runnerURL, bodyBytes)
+func proxyRunnerStream(runnerURL string, bodyBytes []byte, sessionName, runID, threadID string, actor aguiActor, reqID string) {
+	aguiLog.Info("AGUI Proxy: connecting to runner", "reqId", reqID, "runnerUrl", runnerURL)
+	resp, err := connectToRunner(runnerURL, bodyBytes, reqID)
 	if err != nil {
-		log.Printf("AGUI Proxy: runner unavailable for %s: %v", sessionName, err)
+		aguiLog.Error("AGUI Proxy: runner unavailable", "reqId", reqID, "session", sessionName, "err", err)
 		// Publish error events so GET /agui/events subscribers see the failure
-		publishAndPersistErrorEvents(sessionName, runID, threadID, "Runner is not available")
+		publishAndPersistErrorEvents(sessionName, runID, threadID, actor, reqID, "Runner is not available")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("AGUI Proxy: runner returned %d: %s", resp.StatusCode, string(body))
-		publishAndPersistErrorEvents(sessionName, runID, threadID, fmt.Sprintf("Runner error: HTTP %d", resp.StatusCode))
+		aguiLog.Error("AGUI Proxy: runner returned error status", "reqId", reqID, "status", resp.StatusCode, "body", string(body))
+		publishAndPersistErrorEvents(sessionName, runID, threadID, actor, reqID, fmt.Sprintf("Runner error: HTTP %d", resp.StatusCode))
 		return
 	}
 
@@ -248,50 +333,58 @@ func proxyRunnerStream(runnerURL string, bodyBytes []byte, sessionName, runID, t
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			if err != io.EOF {
-				log.Printf("AGUI Proxy: stream read error: %v", err)
+				aguiLog.Error("AGUI Proxy: stream read error", "reqId", reqID, "err", err)
 			}
 			break
 		}
+		recordBytesStreamed(len(line))
 
 		trimmed := strings.TrimSpace(line)
 
-		// Persist every data event to JSONL
+		// Persist every data event to JSONL, tagging the live broadcast
+		// with its cursor so subscribers can track Last-Event-ID.
 		if strings.HasPrefix(trimmed, "data: ") {
 			jsonData := strings.TrimPrefix(trimmed, "data: ")
-			persistStreamedEvent(sessionName, runID, threadID, jsonData)
+			cursor := persistStreamedEvent(sessionName, runID, threadID, actor, reqID, jsonData)
+			publishLine(sessionName, sseDataLine(cursor.String(), []byte(jsonData)))
+			continue
 		}
 
-		// Publish raw SSE line to all GET /agui/events subscribers
+		// Non-data lines (blank separators, SSE comments) pass through as-is.
 		publishLine(sessionName, line)
 	}
 
-	log.Printf("AGUI Proxy: run %s stream ended", truncID(runID))
+	aguiLog.Info("AGUI Proxy: run stream ended", "reqId", reqID, "runId", truncID(runID))
 }
 
 // publishAndPersistErrorEvents generates RUN_STARTED + RUN_ERROR events,
 // persists them, and publishes to the live broadcast so subscribers get
-// notified of runner failures.
-func publishAndPersistErrorEvents(sessionName, runID, threadID, message string) {
+// notified of runner failures. reqID is attached to the RUN_ERROR payload
+// so a frontend bug report naming it can be matched straight back to the
+// backend (and runner) logs for this request — see agui_logging.go.
+func publishAndPersistErrorEvents(sessionName, runID, threadID string, actor aguiActor, reqID, message string) {
 	// RUN_STARTED
 	startEvt := map[string]interface{}{
 		"type":     "RUN_STARTED",
 		"threadId": threadID,
 		"runId":    runID,
 	}
-	persistEvent(sessionName, startEvt)
+	attachActor(startEvt, actor)
+	startCursor := persistEvent(sessionName, startEvt)
 	startData, _ := json.Marshal(startEvt)
-	publishLine(sessionName, fmt.Sprintf("data: %s\n\n", startData))
+	publishLine(sessionName, sseDataLine(startCursor.String(), startData))
 
 	// RUN_ERROR
 	errEvt := map[string]interface{}{
-		"type":     "RUN_ERROR",
-		"message":  message,
-		"threadId": threadID,
-		"runId":    runID,
+		"type":      "RUN_ERROR",
+		"message":   message,
+		"threadId":  threadID,
+		"runId":     runID,
+		"requestId": reqID,
 	}
-	persistEvent(sessionName, errEvt)
+	errCursor := persistEvent(sessionName, errEvt)
 	errData, _ := json.Marshal(errEvt)
-	publishLine(sessionName, fmt.Sprintf("data: %s\n\n", errData))
+	publishLine(sessionName, sseDataLine(errCursor.String(), errData))
 }
 
 // ─── Hidden message helpers ──────────────────────────────────────────
@@ -311,8 +404,11 @@ func isMessageHidden(metadata interface{}) bool {
 
 // emitHiddenMessageMetadata persists and broadcasts a RAW event that
 // tells the frontend to hide a specific message (e.g. auto-sent workflow
-// prompts or initial prompts).
-func emitHiddenMessageMetadata(sessionName, runID, threadID, messageID string) {
+// prompts or initial prompts). reqID is not persisted on the event
+// itself; it only tags this function's debug log line, like every other
+// step of startAGUIRun.
+func emitHiddenMessageMetadata(sessionName, runID, threadID, messageID, reqID string) {
+	aguiLog.Debug("AGUI Proxy: emitting hidden message metadata", "reqId", reqID, "session", sessionName, "messageId", messageID)
 	evt := map[string]interface{}{
 		"type":     "RAW",
 		"threadId": threadID,
@@ -323,22 +419,24 @@ func emitHiddenMessageMetadata(sessionName, runID, threadID, messageID string) {
 			"hidden":    true,
 		},
 	}
-	persistEvent(sessionName, evt)
+	cursor := persistEvent(sessionName, evt)
 	data, _ := json.Marshal(evt)
-	publishLine(sessionName, fmt.Sprintf("data: %s\n\n", data))
+	publishLine(sessionName, sseDataLine(cursor.String(), data))
 }
 
-// persistStreamedEvent parses a raw JSON event, ensures IDs, and
-// appends it to the event log.  No in-memory state, no broadcasting.
+// persistStreamedEvent parses a raw JSON event, ensures IDs, appends
+// it to the event log, and returns the cursor it was assigned.  No
+// in-memory state, no broadcasting.
 //
 // NOTE: We intentionally do NOT inject timestamps.  The AG-UI spec
 // defines timestamp as z.number().optional() (epoch ms).  If the
 // runner omits it, the field stays absent — the proxy should not
 // invent fields the source didn't emit.
-func persistStreamedEvent(sessionID, runID, threadID, jsonData string) {
+func persistStreamedEvent(sessionID, runID, threadID string, actor aguiActor, reqID, jsonData string) eventCursor {
 	var event map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
-		return
+		aguiLog.Error("AGUI Proxy: failed to parse runner event", "reqId", reqID, "session", sessionID, "err", err)
+		return eventCursor{}
 	}
 
 	// Ensure required fields (threadId + runId are needed for compaction)
@@ -349,13 +447,38 @@ func persistStreamedEvent(sessionID, runID, threadID, jsonData string) {
 		event["runId"] = runID
 	}
 
-	persistEvent(sessionID, event)
+	// RUN_STARTED is the one event the runner itself emits for this run,
+	// so it's where the actor who triggered the run is recorded — this is
+	// what makes the event log auditable, not just the server logs.
+	if t, _ := event["type"].(string); t == "RUN_STARTED" {
+		attachActor(event, actor)
+	}
+
+	return persistEvent(sessionID, event)
+}
+
+// attachActor sets the "actor" field audit-logged events carry, omitting
+// it entirely when neither an IP nor a user claim was recovered (e.g. a
+// direct backend-internal call with no HTTP request behind it).
+func attachActor(event map[string]interface{}, actor aguiActor) {
+	if actor.IP == "" && actor.User == "" {
+		return
+	}
+	event["actor"] = map[string]interface{}{
+		"ip":   actor.IP,
+		"user": actor.User,
+	}
 }
 
 // ─── POST /agui/interrupt ────────────────────────────────────────────
 
 // HandleAGUIInterrupt sends interrupt signal to the runner.
-func HandleAGUIInterrupt(c *gin.Context) {
+//
+// Wrapped with the short-request in-flight limiter and timeout — see
+// agui_limiter.go.
+var HandleAGUIInterrupt = WithAGUIShortLimit(handleAGUIInterruptImpl)
+
+func handleAGUIInterruptImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -371,30 +494,67 @@ func HandleAGUIInterrupt(c *gin.Context) {
 		return
 	}
 
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+
+	if err := sendInterruptToRunner(c.Request.Context(), projectName, sessionName, reqID); err != nil {
+		c.JSON(statusFromRunnerError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Interrupt signal sent"})
+}
+
+// runnerStatusError carries the runner's HTTP status code alongside the
+// error message, so callers (HTTP handler or WebSocket frame dispatch)
+// can each report it in their own wire format.
+type runnerStatusError struct {
+	status int
+	msg    string
+}
+
+func (e *runnerStatusError) Error() string { return e.msg }
+
+// statusFromRunnerError maps a sendInterruptToRunner/sendFeedbackToRunner
+// error to an HTTP status for JSON responses; defaults to 502 for
+// transport-level failures that don't carry a runner status.
+func statusFromRunnerError(err error) int {
+	if rse, ok := err.(*runnerStatusError); ok {
+		return rse.status
+	}
+	return http.StatusBadGateway
+}
+
+// sendInterruptToRunner forwards an interrupt signal to the session's
+// runner pod. Shared by HandleAGUIInterrupt (HTTP) and the WebSocket
+// "interrupt" frame so both transports use identical runner semantics.
+// ctx is the caller's request context — for HandleAGUIInterrupt this
+// carries the short-request deadline set by WithAGUIShortLimit, so a
+// stuck runner doesn't hold the handler (and its in-flight slot) open
+// past that bound. reqID is set as an outbound X-Request-Id header so the
+// runner's own logs can be correlated with this request.
+func sendInterruptToRunner(ctx context.Context, projectName, sessionName, reqID string) error {
 	runnerURL := getRunnerEndpoint(projectName, sessionName)
 	interruptURL := strings.TrimSuffix(runnerURL, "/") + "/interrupt"
 
-	req, err := http.NewRequest("POST", interruptURL, bytes.NewReader([]byte("{}")))
+	req, err := http.NewRequestWithContext(ctx, "POST", interruptURL, bytes.NewReader([]byte("{}")))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", reqID)
 
 	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{"error": string(body)})
-		return
+		return &runnerStatusError{status: resp.StatusCode, msg: string(body)}
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Interrupt signal sent"})
+	return nil
 }
 
 // ─── POST /agui/feedback ─────────────────────────────────────────────
@@ -406,7 +566,12 @@ func HandleAGUIInterrupt(c *gin.Context) {
 // RAW events don't need to be within run boundaries (RUN_STARTED/
 // RUN_FINISHED), unlike CUSTOM events which cause AG-UI validation
 // errors when replayed outside a run.
-func HandleAGUIFeedback(c *gin.Context) {
+//
+// Wrapped with the short-request in-flight limiter and timeout — see
+// agui_limiter.go.
+var HandleAGUIFeedback = WithAGUIShortLimit(handleAGUIFeedbackImpl)
+
+func handleAGUIFeedbackImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -434,38 +599,54 @@ func HandleAGUIFeedback(c *gin.Context) {
 		return
 	}
 
-	// Forward to runner — it sends to Langfuse and returns a RAW event
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+
+	message, status, err := sendFeedbackToRunner(c.Request.Context(), projectName, sessionName, metaEvent, reqID)
+	if err != nil {
+		c.JSON(statusFromRunnerError(err), gin.H{"error": err.Error(), "status": "failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "status": status})
+}
+
+// sendFeedbackToRunner forwards a META feedback event to the runner,
+// which relays it to Langfuse and returns a RAW event; that RAW event
+// is persisted directly (no run wrapping needed — see doc comment on
+// HandleAGUIFeedback). Shared by HandleAGUIFeedback (HTTP) and the
+// WebSocket "feedback" frame. ctx carries the short-request deadline —
+// see sendInterruptToRunner. reqID is set as an outbound X-Request-Id
+// header so the runner's own logs can be correlated with this request.
+func sendFeedbackToRunner(ctx context.Context, projectName, sessionName string, metaEvent map[string]interface{}, reqID string) (message string, status string, err error) {
 	runnerURL := getRunnerEndpoint(projectName, sessionName)
 	feedbackURL := strings.TrimSuffix(runnerURL, "/") + "/feedback"
 
 	bodyBytes, _ := json.Marshal(metaEvent)
-	req, err := http.NewRequest("POST", feedbackURL, bytes.NewReader(bodyBytes))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return
+	req, reqErr := http.NewRequestWithContext(ctx, "POST", feedbackURL, bytes.NewReader(bodyBytes))
+	if reqErr != nil {
+		return "", "", reqErr
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", reqID)
 
-	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
-	if err != nil {
-		c.JSON(http.StatusAccepted, gin.H{"error": "Runner unavailable — feedback not recorded", "status": "failed"})
-		return
+	resp, doErr := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if doErr != nil {
+		return "", "", &runnerStatusError{status: http.StatusAccepted, msg: "Runner unavailable — feedback not recorded"}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("AGUI Feedback: runner returned %d for %s: %s", resp.StatusCode, sessionName, string(body))
-		c.JSON(resp.StatusCode, gin.H{"error": "Runner rejected feedback", "status": "failed"})
-		return
+		aguiLog.Error("AGUI Feedback: runner returned error status", "reqId", reqID, "status", resp.StatusCode, "session", sessionName, "body", string(body))
+		return "", "", &runnerStatusError{status: resp.StatusCode, msg: "Runner rejected feedback"}
 	}
 
 	// Runner returned a RAW event — persist it directly (no run wrapping needed).
 	var rawEvent map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&rawEvent); err != nil {
-		log.Printf("AGUI Feedback: failed to decode runner response for %s: %v", sessionName, err)
-		c.JSON(http.StatusOK, gin.H{"message": "Feedback sent but not persisted", "status": "sent"})
-		return
+	if decErr := json.NewDecoder(resp.Body).Decode(&rawEvent); decErr != nil {
+		aguiLog.Error("AGUI Feedback: failed to decode runner response", "reqId", reqID, "session", sessionName, "err", decErr)
+		return "Feedback sent but not persisted", "sent", nil
 	}
 
 	go func() {
@@ -474,13 +655,18 @@ func HandleAGUIFeedback(c *gin.Context) {
 		persistEvent(sessionName, rawEvent)
 	}()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Feedback submitted", "status": "sent"})
+	return "Feedback submitted", "sent", nil
 }
 
 // ─── GET /agui/capabilities ──────────────────────────────────────────
 
 // HandleCapabilities proxies GET /capabilities to the runner.
-func HandleCapabilities(c *gin.Context) {
+//
+// Wrapped with the short-request in-flight limiter and timeout — see
+// agui_limiter.go.
+var HandleCapabilities = WithAGUIShortLimit(handleCapabilitiesImpl)
+
+func handleCapabilitiesImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -496,14 +682,18 @@ func HandleCapabilities(c *gin.Context) {
 		return
 	}
 
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+
 	runnerURL := getRunnerEndpoint(projectName, sessionName)
 	capURL := strings.TrimSuffix(runnerURL, "/") + "/capabilities"
 
-	req, err := http.NewRequest("GET", capURL, nil)
+	req, err := http.NewRequestWithContext(c.Request.Context(), "GET", capURL, nil)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"framework": "unknown"})
 		return
 	}
+	req.Header.Set("X-Request-Id", reqID)
 	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -528,7 +718,12 @@ func HandleCapabilities(c *gin.Context) {
 // ─── GET /mcp/status ─────────────────────────────────────────────────
 
 // HandleMCPStatus proxies MCP status requests to the runner.
-func HandleMCPStatus(c *gin.Context) {
+//
+// Wrapped with the short-request in-flight limiter and timeout — see
+// agui_limiter.go.
+var HandleMCPStatus = WithAGUIShortLimit(handleMCPStatusImpl)
+
+func handleMCPStatusImpl(c *gin.Context) {
 	projectName := c.Param("projectName")
 	sessionName := c.Param("sessionName")
 
@@ -544,14 +739,18 @@ func HandleMCPStatus(c *gin.Context) {
 		return
 	}
 
+	reqID := newRequestID()
+	c.Header("X-Request-Id", reqID)
+
 	runnerURL := getRunnerEndpoint(projectName, sessionName)
 	mcpURL := strings.TrimSuffix(runnerURL, "/") + "/mcp/status"
 
-	req, err := http.NewRequest("GET", mcpURL, nil)
+	req, err := http.NewRequestWithContext(c.Request.Context(), "GET", mcpURL, nil)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"servers": []interface{}{}, "totalCount": 0})
 		return
 	}
+	req.Header.Set("X-Request-Id", reqID)
 	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"servers": []interface{}{}, "totalCount": 0})
@@ -589,9 +788,13 @@ var runnerHTTPClient = &http.Client{
 //   - 2 attempts max
 //   - Immediate fail on "no such host" (runner pod doesn't exist)
 //   - 1s retry only on "connection refused" (runner still starting)
-func connectToRunner(runnerURL string, bodyBytes []byte) (*http.Response, error) {
+//
+// reqID is set as an outbound X-Request-Id header so the runner's own
+// logs can be correlated with the request that triggered this run.
+func connectToRunner(runnerURL string, bodyBytes []byte, reqID string) (*http.Response, error) {
 	maxAttempts := 2
 	retryDelay := 1 * time.Second
+	start := time.Now()
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		req, err := http.NewRequest("POST", runnerURL, bytes.NewReader(bodyBytes))
@@ -600,9 +803,11 @@ func connectToRunner(runnerURL string, bodyBytes []byte) (*http.Response, error)
 		}
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("X-Request-Id", reqID)
 
 		resp, err := runnerHTTPClient.Do(req)
 		if err == nil {
+			aguiRunnerTTFBSeconds.Observe(time.Since(start).Seconds())
 			return resp, nil
 		}
 
@@ -618,7 +823,7 @@ func connectToRunner(runnerURL string, bodyBytes []byte) (*http.Response, error)
 		}
 
 		if attempt < maxAttempts {
-			log.Printf("AGUI Proxy: runner not ready (attempt %d/%d), retrying in %v", attempt, maxAttempts, retryDelay)
+			aguiLog.Warn("AGUI Proxy: runner not ready, retrying", "reqId", reqID, "attempt", attempt, "maxAttempts", maxAttempts, "retryDelay", retryDelay)
 			time.Sleep(retryDelay)
 		}
 	}
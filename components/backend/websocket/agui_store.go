@@ -2,8 +2,15 @@
 //
 // agui_store.go — Event persistence, compaction, and replay.
 //
-// Write path:  append every event to agui-events.jsonl.
-// Read path:   load + compact events for reconnect replay.
+// Write path:  each process appends to its own writer file,
+//
+//	agui-events.<writerID>.jsonl, so concurrent replicas sharing
+//	STATE_BASE_DIR over NFS/EFS never interleave appends.
+//
+// Read path:   merge all writer files for a session in
+//
+//	(timestamp, writerID, seq) order, then compact for replay.
+//
 // Compaction:  Go port of @ag-ui/client compactEvents — concatenates
 //
 //	TEXT_MESSAGE_CONTENT and TOOL_CALL_ARGS deltas.
@@ -11,11 +18,17 @@ package websocket
 
 import (
 	"ambient-code-backend/types"
+	"bufio"
+	"container/heap"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -53,6 +66,38 @@ func evictStaleWriteMutexes() {
 // Set from the STATE_BASE_DIR env var (default "/workspace") at startup.
 var StateBaseDir string
 
+// ─── Writer identity ─────────────────────────────────────────────────
+// Each backend process owns one writer file per session
+// (agui-events.<writerID>.jsonl) so two replicas sharing STATE_BASE_DIR
+// over a shared volume (NFS/EFS/PVC ReadWriteMany) never interleave
+// appends — POSIX O_APPEND atomicity isn't guaranteed on those
+// filesystems. writerID is resolved once at startup and never changes
+// for the life of the process.
+
+// writerID identifies this process's writer file across all sessions.
+// Resolved once at package init from hostname + PID + start time.
+var writerID = computeWriterID()
+
+// singleWriterCompat pins the legacy single-file layout
+// (agui-events.jsonl, no per-writer split) for rollout safety.
+// Set AGUI_SINGLE_WRITER_COMPAT=true to enable.
+var singleWriterCompat = os.Getenv("AGUI_SINGLE_WRITER_COMPAT") == "true"
+
+func computeWriterID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", sanitizeWriterComponent(host), os.Getpid(), time.Now().UnixNano())
+}
+
+// sanitizeWriterComponent strips characters that would be awkward in a
+// filename (e.g. pod names containing dots in some clusters are fine,
+// but be defensive about separators we rely on when parsing).
+func sanitizeWriterComponent(s string) string {
+	return strings.ReplaceAll(s, ".", "-")
+}
+
 // ─── Live event pipe (multi-client broadcast) ───────────────────────
 // The run handler pipes raw SSE lines to ALL connect handlers tailing
 // the same session.  Zero latency — same as the direct run() path.
@@ -81,6 +126,7 @@ func publishLine(sessionName, line string) {
 		select {
 		case ch <- line:
 		default: // slow client — drop (it's persisted to JSONL)
+			recordEventDropped()
 		}
 	}
 }
@@ -97,10 +143,13 @@ func subscribeLive(sessionName string) (<-chan string, func()) {
 	b.subs[id] = ch
 	b.mu.Unlock()
 
+	recordSubscriberJoin(sessionName)
+
 	return ch, func() {
 		b.mu.Lock()
 		delete(b.subs, id)
 		b.mu.Unlock()
+		recordSubscriberLeave(sessionName)
 	}
 }
 
@@ -126,13 +175,217 @@ func getWriteMutex(sessionID string) *sync.Mutex {
 	return &entry.mu
 }
 
-// persistEvent appends a single AG-UI event to the session's JSONL log.
-// Writes are serialised per-session via a mutex to prevent interleaving.
-func persistEvent(sessionID string, event map[string]interface{}) {
-	dir := fmt.Sprintf("%s/sessions/%s", StateBaseDir, sessionID)
-	path := dir + "/agui-events.jsonl"
+// sessionDir returns the on-disk directory holding a session's state.
+func sessionDir(sessionID string) string {
+	return fmt.Sprintf("%s/sessions/%s", StateBaseDir, sessionID)
+}
+
+// legacyEventLogPath is the pre-multi-writer single-file log.
+func legacyEventLogPath(sessionID string) string {
+	return sessionDir(sessionID) + "/agui-events.jsonl"
+}
+
+// writerEventLogPath is this process's own writer file for a session.
+// persistEvent only ever appends here, so no cross-process locking is
+// required even on shared storage.
+func writerEventLogPath(sessionID string) string {
+	return fmt.Sprintf("%s/agui-events.%s.jsonl", sessionDir(sessionID), writerID)
+}
+
+// writerLogGlob matches every writer file (including sealed
+// compacted-<epoch> files) for a session.
+func writerLogGlob(sessionID string) string {
+	return sessionDir(sessionID) + "/agui-events.*.jsonl"
+}
+
+// writerSessionState tracks per-session state for this process's own
+// writer file: the next sequence number to assign, and the active
+// file's current size (for rotation). Callers always hold the
+// session's write mutex (getWriteMutex) while touching its fields, so
+// they need no lock of their own; the writerStates map itself spans
+// sessions and is guarded by writerStatesMu.
+type writerSessionState struct {
+	seq  int64
+	size int64
+}
+
+var writerStates = make(map[string]*writerSessionState)
+var writerStatesMu sync.Mutex
+
+func getWriterState(sessionID string) *writerSessionState {
+	writerStatesMu.Lock()
+	defer writerStatesMu.Unlock()
+	st, ok := writerStates[sessionID]
+	if !ok {
+		st = &writerSessionState{}
+		writerStates[sessionID] = st
+	}
+	return st
+}
+
+func nextWriterSeq(sessionID string) int64 {
+	st := getWriterState(sessionID)
+	st.seq++
+	return st.seq
+}
+
+// writerRotateBytes is the size threshold past which an active writer
+// file is sealed as a segment and a fresh one started. Set via
+// AGUI_LOG_ROTATE_BYTES (bytes); 0 disables rotation.
+var writerRotateBytes = writerRotateBytesFromEnv()
+
+func writerRotateBytesFromEnv() int64 {
+	raw := os.Getenv("AGUI_LOG_ROTATE_BYTES")
+	if raw == "" {
+		return 64 * 1024 * 1024 // 64MiB default
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// rotateWriterFileIfNeeded seals the active writer file as
+// agui-events.<writerID>.<epoch>.seg.jsonl once it exceeds
+// writerRotateBytes, so a single writer's own file doesn't grow
+// unbounded across a long session. Must be called with the session's
+// write mutex held.
+func rotateWriterFileIfNeeded(sessionID string, st *writerSessionState) {
+	if writerRotateBytes <= 0 || st.size < writerRotateBytes {
+		return
+	}
+	active := writerEventLogPath(sessionID)
+	sealed := fmt.Sprintf("%s/agui-events.%s.%d.seg.jsonl", sessionDir(sessionID), writerID, time.Now().UnixNano())
+	if err := os.Rename(active, sealed); err != nil {
+		log.Printf("AGUI Store: failed to rotate writer file for %s: %v", sessionID, err)
+		return
+	}
+	st.size = 0
+}
+
+// storedEvent is the on-disk envelope around a persisted AG-UI event.
+// The envelope carries the merge key (ts, writerID, seq); "event" is
+// passed through to callers untouched, preserving the AG-UI wire
+// schema (we never invent fields the source didn't emit).
+type storedEvent struct {
+	WriterID string                 `json:"writerId"`
+	Seq      int64                  `json:"seq"`
+	TS       int64                  `json:"ts"`
+	Event    map[string]interface{} `json:"event"`
+}
+
+// eventCursor identifies one persisted event for SSE Last-Event-ID
+// resume. Because writers never coordinate across processes (that's
+// the whole point of one file per writer — see writerEventLogPath), a
+// single global integer isn't available without a shared counter that
+// would reintroduce the cross-process race this log format exists to
+// avoid. writerID+seq is assigned locally at write time with no
+// coordination and is globally unique and stable for the life of the
+// event, which is everything Last-Event-ID needs.
+type eventCursor struct {
+	WriterID string
+	Seq      int64
+}
+
+func (c eventCursor) String() string {
+	if c.WriterID == "" {
+		return ""
+	}
+	return c.WriterID + ":" + strconv.FormatInt(c.Seq, 10)
+}
+
+// pendingReplayCursorEvent buffers one compacted event alongside the
+// cursor it was emitted for, so a finished-run resume can fall back to a
+// full replay if the client's Last-Event-ID cursor is never matched
+// (e.g. it no longer exists in the session's history) — see
+// HandleAGUIEvents/HandleAGUIWebSocket.
+type pendingReplayCursorEvent struct {
+	evt    map[string]interface{}
+	cursor eventCursor
+}
+
+// parseEventCursor parses the id produced by eventCursor.String().
+// Returns ok=false for malformed or empty input.
+func parseEventCursor(raw string) (eventCursor, bool) {
+	idx := strings.LastIndex(raw, ":")
+	if idx <= 0 || idx == len(raw)-1 {
+		return eventCursor{}, false
+	}
+	seq, err := strconv.ParseInt(raw[idx+1:], 10, 64)
+	if err != nil {
+		return eventCursor{}, false
+	}
+	return eventCursor{WriterID: raw[:idx], Seq: seq}, true
+}
+
+// persistEvent appends a single AG-UI event to this process's writer
+// file for the session and returns the cursor assigned to it (the
+// zero cursor in AGUI_SINGLE_WRITER_COMPAT mode, where no envelope is
+// written). Because each writer owns its own file, no cross-process
+// locking is needed on shared storage (NFS/EFS/PVC ReadWriteMany) —
+// only the in-process per-session mutex is needed to keep this
+// process's own appends (and its seq counter) ordered.
+func persistEvent(sessionID string, event map[string]interface{}) eventCursor {
+	dir := sessionDir(sessionID)
 	_ = ensureDir(dir)
 
+	if singleWriterCompat {
+		persistEventLegacy(sessionID, event)
+		recordEventPersisted(sessionID)
+		return eventCursor{}
+	}
+
+	mu := getWriteMutex(sessionID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	st := getWriterState(sessionID)
+	rotateWriterFileIfNeeded(sessionID, st)
+
+	cursor := eventCursor{WriterID: writerID, Seq: nextWriterSeq(sessionID)}
+	rec := storedEvent{
+		WriterID: cursor.WriterID,
+		Seq:      cursor.Seq,
+		TS:       time.Now().UnixMilli(),
+		Event:    event,
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("AGUI Store: failed to marshal event: %v", err)
+		return eventCursor{}
+	}
+	data = append(data, '\n')
+
+	f, err := openFileAppend(writerEventLogPath(sessionID))
+	if err != nil {
+		log.Printf("AGUI Store: failed to open event log: %v", err)
+		return eventCursor{}
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		log.Printf("AGUI Store: failed to write event: %v", err)
+		return eventCursor{}
+	}
+	st.size += int64(len(data))
+	recordEventPersisted(sessionID)
+
+	// RUN_FINISHED marks the end of a run: this is a natural point to
+	// rewrite the writer's own file through compactStreamingEvents so
+	// the next reconnect doesn't have to re-compact raw deltas from
+	// disk every time.
+	if eventType, _ := event["type"].(string); eventType == types.EventTypeRunFinished {
+		go compactWriterFileInPlace(sessionID)
+	}
+
+	return cursor
+}
+
+// persistEventLegacy appends to the single shared agui-events.jsonl,
+// for operators pinning the pre-multi-writer behaviour during rollout
+// via AGUI_SINGLE_WRITER_COMPAT.
+func persistEventLegacy(sessionID string, event map[string]interface{}) {
 	data, err := json.Marshal(event)
 	if err != nil {
 		log.Printf("AGUI Store: failed to marshal event: %v", err)
@@ -143,7 +396,7 @@ func persistEvent(sessionID string, event map[string]interface{}) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	f, err := openFileAppend(path)
+	f, err := openFileAppend(legacyEventLogPath(sessionID))
 	if err != nil {
 		log.Printf("AGUI Store: failed to open event log: %v", err)
 		return
@@ -157,20 +410,159 @@ func persistEvent(sessionID string, event map[string]interface{}) {
 
 // ─── Read path ───────────────────────────────────────────────────────
 
-// loadEvents reads all AG-UI events for a session from the JSONL log.
-// Automatically triggers legacy migration if the log doesn't exist but
-// a pre-AG-UI messages.jsonl file does.
+// migrateLegacyEventLog renames a pre-multi-writer agui-events.jsonl to
+// agui-events.legacy.jsonl on first read, so it merges alongside any
+// per-writer files going forward. Idempotent: a missing legacy file is
+// not an error.
+func migrateLegacyEventLog(sessionID string) {
+	legacyPath := legacyEventLogPath(sessionID)
+	if _, err := os.Stat(legacyPath); err != nil {
+		return
+	}
+	target := fmt.Sprintf("%s/agui-events.legacy.jsonl", sessionDir(sessionID))
+	if err := os.Rename(legacyPath, target); err != nil && !os.IsNotExist(err) {
+		log.Printf("AGUI Store: failed to migrate legacy event log for %s: %v", sessionID, err)
+	}
+}
+
+// loadRecords reads and merges every writer file's storedEvent
+// envelopes for a session, ordered by (ts, writerID, seq) — that
+// tiebreaker ordering guarantees intra-writer order is preserved even
+// when two writers' timestamps collide. Automatically migrates a
+// legacy single-file log on first read, and falls back to the
+// pre-AG-UI messages.jsonl migration if no event log exists at all.
+func loadRecords(sessionID string) []storedEvent {
+	migrateLegacyEventLog(sessionID)
+
+	matches, _ := filepath.Glob(writerLogGlob(sessionID))
+	if len(matches) == 0 {
+		// No writer files yet — attempt legacy messages.jsonl migration,
+		// then retry once.
+		if mErr := MigrateLegacySessionToAGUI(sessionID); mErr != nil {
+			log.Printf("AGUI Store: legacy migration failed for %s: %v", sessionID, mErr)
+		}
+		migrateLegacyEventLog(sessionID)
+		matches, _ = filepath.Glob(writerLogGlob(sessionID))
+		if len(matches) == 0 {
+			return nil
+		}
+	}
+
+	var records []storedEvent
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("AGUI Store: failed to read event log %s: %v", path, err)
+			continue
+		}
+		writer := writerIDFromPath(path)
+		for i, line := range splitLines(data) {
+			if len(line) == 0 {
+				continue
+			}
+			var rec storedEvent
+			if err := json.Unmarshal(line, &rec); err == nil && rec.Event != nil {
+				if rec.WriterID == "" {
+					rec.WriterID = writer
+				}
+				records = append(records, rec)
+				continue
+			}
+
+			// Lines from before the storedEvent envelope existed — written
+			// by migrateLegacyEventLog's renamed file or by
+			// MigrateLegacySessionToAGUI — are raw AG-UI events with no
+			// envelope, so unmarshaling into storedEvent leaves Event nil.
+			// Wrap them here instead of silently dropping pre-existing
+			// session history: seq is the line's position, which is
+			// stable and monotonic within the file; ts is 0 so legacy
+			// events always sort ahead of any event persisted after
+			// migration (which carries a real wall-clock timestamp).
+			var raw map[string]interface{}
+			if err := json.Unmarshal(line, &raw); err != nil || raw["type"] == nil {
+				continue
+			}
+			records = append(records, storedEvent{
+				WriterID: writer,
+				Seq:      int64(i),
+				TS:       0,
+				Event:    raw,
+			})
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].TS != records[j].TS {
+			return records[i].TS < records[j].TS
+		}
+		if records[i].WriterID != records[j].WriterID {
+			return records[i].WriterID < records[j].WriterID
+		}
+		return records[i].Seq < records[j].Seq
+	})
+
+	return records
+}
+
+// loadEvents reads and merges all AG-UI events for a session, in
+// persisted order, discarding their cursors. Use loadEventsWithIDs
+// when the caller needs SSE Last-Event-ID support.
 func loadEvents(sessionID string) []map[string]interface{} {
-	path := fmt.Sprintf("%s/sessions/%s/agui-events.jsonl", StateBaseDir, sessionID)
+	if singleWriterCompat {
+		return loadEventsLegacy(sessionID)
+	}
+
+	records := loadRecords(sessionID)
+	events := make([]map[string]interface{}, 0, len(records))
+	for _, rec := range records {
+		events = append(events, rec.Event)
+	}
+	return events
+}
+
+// loadEventsWithIDs is loadEvents plus each event's cursor, for
+// callers that need to honor SSE Last-Event-ID (see HandleAGUIEvents).
+// Returns nil IDs in AGUI_SINGLE_WRITER_COMPAT mode, where events
+// carry no cursor.
+func loadEventsWithIDs(sessionID string) ([]map[string]interface{}, []eventCursor) {
+	if singleWriterCompat {
+		return loadEventsLegacy(sessionID), nil
+	}
+
+	records := loadRecords(sessionID)
+	events := make([]map[string]interface{}, 0, len(records))
+	ids := make([]eventCursor, 0, len(records))
+	for _, rec := range records {
+		events = append(events, rec.Event)
+		ids = append(ids, eventCursor{WriterID: rec.WriterID, Seq: rec.Seq})
+	}
+	return events, ids
+}
+
+// writerIDFromPath extracts the writerID component out of
+// agui-events.<writerID>.jsonl, used as a sort tiebreaker when a
+// record predates the writerId field being embedded in the envelope.
+func writerIDFromPath(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	parts := strings.SplitN(base, ".", 2)
+	if len(parts) == 2 {
+		return parts[1]
+	}
+	return base
+}
+
+// loadEventsLegacy reads the single shared agui-events.jsonl, for
+// operators pinning the pre-multi-writer behaviour via
+// AGUI_SINGLE_WRITER_COMPAT.
+func loadEventsLegacy(sessionID string) []map[string]interface{} {
+	path := legacyEventLogPath(sessionID)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Attempt legacy migration (messages.jsonl → agui-events.jsonl)
 			if mErr := MigrateLegacySessionToAGUI(sessionID); mErr != nil {
 				log.Printf("AGUI Store: legacy migration failed for %s: %v", sessionID, mErr)
 			}
-			// Retry after migration
 			data, err = os.ReadFile(path)
 			if err != nil {
 				return nil
@@ -194,6 +586,549 @@ func loadEvents(sessionID string) []map[string]interface{} {
 	return events
 }
 
+// ─── Streaming replay (segment-aware, bounded memory) ────────────────
+//
+// loadEventsIter is the streaming counterpart to loadEvents: it merges
+// every writer/segment file for a session via a min-heap over one
+// bufio.Scanner per file, so no single file (and not the merged
+// history either) is ever held in memory at once. Paired with
+// compactStreamingEventsIter, peak memory for a compacted replay is
+// bounded by the number of currently-open TEXT_MESSAGE/TOOL_CALL
+// sequences, not by total session history.
+
+// eventSource reads one writer/segment file's storedEvent records in
+// order, one line at a time.
+type eventSource struct {
+	writer  string
+	scanner *bufio.Scanner
+	file    *os.File
+	cur     storedEvent
+	ok      bool
+	lines   int64 // non-empty lines read so far; seq for envelope-less lines
+}
+
+func newEventSource(path string) *eventSource {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	src := &eventSource{
+		writer:  writerIDFromPath(path),
+		scanner: bufio.NewScanner(f),
+		file:    f,
+	}
+	src.scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	src.advance()
+	return src
+}
+
+// advance reads the next valid storedEvent record into src.cur,
+// skipping malformed lines. Envelope-less legacy lines are wrapped the
+// same way loadRecords wraps them (seq = line position, ts = 0).
+func (src *eventSource) advance() {
+	for src.scanner.Scan() {
+		line := src.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		lineIdx := src.lines
+		src.lines++
+		var rec storedEvent
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Event == nil {
+			var raw map[string]interface{}
+			if err := json.Unmarshal(line, &raw); err != nil || raw["type"] == nil {
+				continue
+			}
+			rec = storedEvent{WriterID: src.writer, Seq: lineIdx, TS: 0, Event: raw}
+		}
+		if rec.WriterID == "" {
+			rec.WriterID = src.writer
+		}
+		src.cur = rec
+		src.ok = true
+		return
+	}
+	src.ok = false
+	_ = src.file.Close()
+}
+
+// eventSourceHeap orders open sources by their current record's
+// (ts, writerID, seq), matching loadEvents' merge order.
+type eventSourceHeap []*eventSource
+
+func (h eventSourceHeap) Len() int { return len(h) }
+func (h eventSourceHeap) Less(i, j int) bool {
+	a, b := h[i].cur, h[j].cur
+	if a.TS != b.TS {
+		return a.TS < b.TS
+	}
+	if a.WriterID != b.WriterID {
+		return a.WriterID < b.WriterID
+	}
+	return a.Seq < b.Seq
+}
+func (h eventSourceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *eventSourceHeap) Push(x interface{}) { *h = append(*h, x.(*eventSource)) }
+func (h *eventSourceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// newEventSourceHeap opens every writer file for a session (running the
+// same legacy migrations as loadEvents first) and returns a ready-to-pull
+// merge heap plus its close function. Shared by loadEventsIter and
+// loadEventsIterWithIDs so both iterate the exact same merge order.
+func newEventSourceHeap(sessionID string) (*eventSourceHeap, func()) {
+	migrateLegacyEventLog(sessionID)
+
+	matches, _ := filepath.Glob(writerLogGlob(sessionID))
+	if len(matches) == 0 {
+		if mErr := MigrateLegacySessionToAGUI(sessionID); mErr != nil {
+			log.Printf("AGUI Store: legacy migration failed for %s: %v", sessionID, mErr)
+		}
+		migrateLegacyEventLog(sessionID)
+		matches, _ = filepath.Glob(writerLogGlob(sessionID))
+	}
+
+	h := make(eventSourceHeap, 0, len(matches))
+	for _, path := range matches {
+		if src := newEventSource(path); src != nil {
+			if src.ok {
+				h = append(h, src)
+			} else {
+				_ = src.file.Close()
+			}
+		}
+	}
+	heap.Init(&h)
+
+	closeFn := func() {
+		for _, src := range h {
+			_ = src.file.Close()
+		}
+		h = nil
+	}
+	return &h, closeFn
+}
+
+// loadEventsIter returns a pull iterator over every event for a
+// session, merged in (ts, writerID, seq) order, plus a close function
+// that must be called once the caller is done (it releases any file
+// handles left open by an early exit). Triggers the same legacy
+// migrations as loadEvents.
+func loadEventsIter(sessionID string) (next func() (map[string]interface{}, bool), closeFn func()) {
+	h, closeFn := newEventSourceHeap(sessionID)
+
+	next = func() (map[string]interface{}, bool) {
+		if h.Len() == 0 {
+			return nil, false
+		}
+		src := (*h)[0]
+		evt := src.cur.Event
+		src.advance()
+		if src.ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+		return evt, true
+	}
+
+	return next, closeFn
+}
+
+// loadEventsIterWithIDs is loadEventsIter's cursor-aware sibling: it
+// yields each raw event's eventCursor alongside the event itself, so a
+// caller compacting a streaming source (see compactStreamingEventsIterWithIDs)
+// can still carry forward a Last-Event-ID cursor through compaction.
+func loadEventsIterWithIDs(sessionID string) (next func() (map[string]interface{}, eventCursor, bool), closeFn func()) {
+	h, closeFn := newEventSourceHeap(sessionID)
+
+	next = func() (map[string]interface{}, eventCursor, bool) {
+		if h.Len() == 0 {
+			return nil, eventCursor{}, false
+		}
+		src := (*h)[0]
+		evt := src.cur.Event
+		cursor := eventCursor{WriterID: src.cur.WriterID, Seq: src.cur.Seq}
+		src.advance()
+		if src.ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+		return evt, cursor, true
+	}
+
+	return next, closeFn
+}
+
+// lastEvent returns the last event for a session in merge order
+// without materializing the full history — it drains loadEventsIter
+// but only ever retains the most recently yielded event.
+func lastEvent(sessionID string) map[string]interface{} {
+	next, closeFn := loadEventsIter(sessionID)
+	defer closeFn()
+
+	var last map[string]interface{}
+	for {
+		evt, ok := next()
+		if !ok {
+			break
+		}
+		last = evt
+	}
+	return last
+}
+
+// ─── Live writer-file rewrite compaction ─────────────────────────────
+// compactWriterFileInPlace rewrites this process's own active writer
+// file through compactStreamingEvents: read, compact, write to a
+// ".tmp" sibling, fsync, then atomically rename over the live file.
+// Triggered on RUN_FINISHED (see persistEvent) so the next reconnect
+// replays an already-compacted file instead of re-running compaction
+// over raw deltas every time. Holds the session's write mutex for the
+// duration, so concurrent appends simply wait rather than racing the
+// rename.
+func compactWriterFileInPlace(sessionID string) {
+	mu := getWriteMutex(sessionID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := writerEventLogPath(sessionID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("AGUI Store: failed to read writer file for compaction %s: %v", sessionID, err)
+		}
+		return
+	}
+
+	var records []storedEvent
+	cursorTS := make(map[eventCursor]int64)
+	for _, line := range splitLines(data) {
+		var rec storedEvent
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Event == nil {
+			continue
+		}
+		records = append(records, rec)
+		cursorTS[eventCursor{WriterID: rec.WriterID, Seq: rec.Seq}] = rec.TS
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	i := 0
+	next := func() (map[string]interface{}, eventCursor, bool) {
+		if i >= len(records) {
+			return nil, eventCursor{}, false
+		}
+		rec := records[i]
+		i++
+		return rec.Event, eventCursor{WriterID: rec.WriterID, Seq: rec.Seq}, true
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		log.Printf("AGUI Store: failed to open tmp file for compaction %s: %v", sessionID, err)
+		return
+	}
+
+	var size int64
+	var writeErr error
+	// compactStreamingEventsIterWithIDs tags each compacted group with
+	// the cursor of the last raw event it subsumes, so rewriting with
+	// THAT cursor — instead of minting a fresh seq 1..N — keeps every
+	// eventCursor already handed to a client as SSE/WebSocket
+	// Last-Event-ID valid after compaction. Renumbering broke resume
+	// outright: a client's cursor simply ceased to exist in the
+	// rewritten file.
+	compactStreamingEventsIterWithIDs(next, func(evt map[string]interface{}, cursor eventCursor) {
+		if writeErr != nil {
+			return
+		}
+		rec := storedEvent{WriterID: cursor.WriterID, Seq: cursor.Seq, TS: cursorTS[cursor], Event: evt}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return
+		}
+		data = append(data, '\n')
+		if _, err := f.Write(data); err != nil {
+			writeErr = err
+			return
+		}
+		size += int64(len(data))
+	})
+	if writeErr != nil {
+		log.Printf("AGUI Store: failed writing compacted writer file %s: %v", sessionID, writeErr)
+		f.Close()
+		_ = os.Remove(tmpPath)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		log.Printf("AGUI Store: failed to fsync compacted writer file %s: %v", sessionID, err)
+		f.Close()
+		_ = os.Remove(tmpPath)
+		return
+	}
+	f.Close()
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Printf("AGUI Store: failed to rename compacted writer file %s: %v", sessionID, err)
+		_ = os.Remove(tmpPath)
+		return
+	}
+
+	// st.seq already covers every seq assigned to these records (it was
+	// incremented at persist time for each one) — preserving original
+	// cursors here means it must NOT be rewound, or the next append
+	// could re-mint a seq value this compacted file already uses.
+	st := getWriterState(sessionID)
+	st.size = size
+}
+
+// ─── Writer-file compaction ──────────────────────────────────────────
+// Long-lived sessions accumulate one file per writer that has ever
+// touched them (process restarts, redeploys, failovers all mint a new
+// writerID). writerCompactAge bounds that growth: files idle longer
+// than the threshold are folded into a single sealed
+// agui-events.compacted-<epoch>.jsonl and the sources deleted. The age
+// threshold naturally excludes any writer still actively appending —
+// its file's mtime keeps advancing — so this only ever touches writers
+// that are done with the session.
+
+// writerCompactInterval is how often the compactor sweeps sessions.
+// writerCompactAge is set via AGUI_WRITER_COMPACT_MINUTES (default 60);
+// 0 disables the compactor.
+var writerCompactAge = writerCompactAgeFromEnv()
+
+const writerCompactInterval = 10 * time.Minute
+
+func writerCompactAgeFromEnv() time.Duration {
+	raw := os.Getenv("AGUI_WRITER_COMPACT_MINUTES")
+	if raw == "" {
+		return 60 * time.Minute
+	}
+	minutes, err := strconv.Atoi(raw)
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func init() {
+	if writerCompactAge <= 0 || singleWriterCompat {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(writerCompactInterval)
+		for range ticker.C {
+			compactIdleWriterFiles()
+		}
+	}()
+}
+
+// compactIdleWriterFiles scans every session directory and folds idle
+// writer files into one sealed file per session.
+func compactIdleWriterFiles() {
+	sessionDirs, err := filepath.Glob(fmt.Sprintf("%s/sessions/*", StateBaseDir))
+	if err != nil {
+		return
+	}
+	for _, dir := range sessionDirs {
+		sessionID := filepath.Base(dir)
+		if err := compactIdleWriterFilesForSession(sessionID); err != nil {
+			log.Printf("AGUI Store: writer compaction failed for %s: %v", sessionID, err)
+		}
+	}
+}
+
+func compactIdleWriterFilesForSession(sessionID string) error {
+	matches, err := filepath.Glob(writerLogGlob(sessionID))
+	if err != nil || len(matches) < 2 {
+		return err
+	}
+
+	threshold := time.Now().Add(-writerCompactAge)
+	var idle []string
+	for _, path := range matches {
+		if strings.Contains(filepath.Base(path), "compacted-") {
+			continue
+		}
+		info, statErr := os.Stat(path)
+		if statErr != nil || info.ModTime().After(threshold) {
+			continue
+		}
+		idle = append(idle, path)
+	}
+	if len(idle) < 2 {
+		return nil // nothing worth sealing together
+	}
+
+	// Serialise against this process's own writer so we never race a
+	// concurrent append into one of the files we're about to delete.
+	mu := getWriteMutex(sessionID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var records []storedEvent
+	for _, path := range idle {
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			continue
+		}
+		writer := writerIDFromPath(path)
+		for i, line := range splitLines(data) {
+			var rec storedEvent
+			if err := json.Unmarshal(line, &rec); err != nil || rec.Event == nil {
+				// Envelope-less legacy line: wrap it as loadRecords does so
+				// it is carried into the sealed file, not deleted with its source.
+				var raw map[string]interface{}
+				if err := json.Unmarshal(line, &raw); err != nil || raw["type"] == nil {
+					continue
+				}
+				rec = storedEvent{WriterID: writer, Seq: int64(i), TS: 0, Event: raw}
+			}
+			if rec.WriterID == "" {
+				rec.WriterID = writer
+			}
+			records = append(records, rec)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].TS != records[j].TS {
+			return records[i].TS < records[j].TS
+		}
+		if records[i].WriterID != records[j].WriterID {
+			return records[i].WriterID < records[j].WriterID
+		}
+		return records[i].Seq < records[j].Seq
+	})
+
+	sealedPath := fmt.Sprintf("%s/agui-events.compacted-%d.jsonl", sessionDir(sessionID), time.Now().UnixMilli())
+	f, err := os.OpenFile(sealedPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("create sealed file: %w", err)
+	}
+	for _, rec := range records {
+		data, marshalErr := json.Marshal(rec)
+		if marshalErr != nil {
+			continue
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return fmt.Errorf("write sealed file: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("fsync sealed file: %w", err)
+	}
+	f.Close()
+
+	for _, path := range idle {
+		if err := os.Remove(path); err != nil {
+			log.Printf("AGUI Store: failed to remove compacted writer file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// ─── Last-Event-ID resume helpers ─────────────────────────────────────
+
+// resumeResendWindow re-sends a few events at or before the client's
+// Last-Event-ID cursor instead of resuming exactly at the next one.
+// This covers a narrow race between persistEvent's fsync'd write and
+// publishLine's broadcast: a client can observe an id on the live pipe
+// fractionally before (or after, on reconnect to a different path) the
+// corresponding record is durable, so resuming exactly at "cursor+1"
+// risks a gap. Clients already de-duplicate replayed events by id
+// (see InMemoryAgentRunner on the frontend), so a small resend is safe.
+const resumeResendWindow = 3
+
+// resumeIndex returns the index into ids to resume replay from, or 0
+// (replay everything) if lastEventID is empty, malformed, or no longer
+// present (e.g. it belonged to a raw delta that compactWriterFileInPlace
+// folded into a compacted group, which keeps only the cursor of the
+// group's last raw event). When the cursor is found,
+// the result backs off by resumeResendWindow events (see above).
+func resumeIndex(lastEventID string, ids []eventCursor) int {
+	if lastEventID == "" {
+		return 0
+	}
+	cursor, ok := parseEventCursor(lastEventID)
+	if !ok {
+		return 0
+	}
+	for i, id := range ids {
+		if id == cursor {
+			start := i + 1 - resumeResendWindow
+			if start < 0 {
+				start = 0
+			}
+			return start
+		}
+	}
+	return 0
+}
+
+// openSequencesBeforeCursor scans events preceding a resume cursor and
+// returns the TEXT_MESSAGE_START/TOOL_CALL_START events for any
+// sequence that was still open (no matching END seen) at the cursor.
+// HandleAGUIEvents re-sends these before the post-cursor tail so a
+// resuming client — which never saw the original start — can still
+// attribute the deltas that follow to the right messageId/toolCallId.
+func openSequencesBeforeCursor(events []map[string]interface{}) []map[string]interface{} {
+	openText := make(map[string]map[string]interface{})
+	var textOrder []string
+	openTool := make(map[string]map[string]interface{})
+	var toolOrder []string
+
+	for _, evt := range events {
+		switch t, _ := evt["type"].(string); t {
+		case types.EventTypeTextMessageStart:
+			if id, _ := evt["messageId"].(string); id != "" {
+				if _, seen := openText[id]; !seen {
+					textOrder = append(textOrder, id)
+				}
+				openText[id] = evt
+			}
+		case types.EventTypeTextMessageEnd:
+			if id, _ := evt["messageId"].(string); id != "" {
+				delete(openText, id)
+			}
+		case types.EventTypeToolCallStart:
+			if id, _ := evt["toolCallId"].(string); id != "" {
+				if _, seen := openTool[id]; !seen {
+					toolOrder = append(toolOrder, id)
+				}
+				openTool[id] = evt
+			}
+		case types.EventTypeToolCallEnd:
+			if id, _ := evt["toolCallId"].(string); id != "" {
+				delete(openTool, id)
+			}
+		}
+	}
+
+	var resumeStarts []map[string]interface{}
+	for _, id := range textOrder {
+		if evt, ok := openText[id]; ok {
+			resumeStarts = append(resumeStarts, evt)
+		}
+	}
+	for _, id := range toolOrder {
+		if evt, ok := openTool[id]; ok {
+			resumeStarts = append(resumeStarts, evt)
+		}
+	}
+	return resumeStarts
+}
+
 // ─── Compaction ──────────────────────────────────────────────────────
 //
 // Go port of @ag-ui/client compactEvents.  Concatenates streaming deltas
@@ -215,9 +1150,38 @@ type pendingTool struct {
 
 // compactStreamingEvents concatenates TEXT_MESSAGE_CONTENT and TOOL_CALL_ARGS
 // deltas for the same messageId/toolCallId.  All other events pass through.
+//
+// This is a thin wrapper around compactStreamingEventsIter for callers
+// that already hold the full event list in memory (e.g. a finished,
+// reasonably small run). For large sessions, prefer
+// compactStreamingEventsIter directly against a streaming source —
+// peak memory there is bounded by currently-open sequences, not by
+// total history size.
 func compactStreamingEvents(events []map[string]interface{}) []map[string]interface{} {
+	i := 0
+	next := func() (map[string]interface{}, bool) {
+		if i >= len(events) {
+			return nil, false
+		}
+		evt := events[i]
+		i++
+		return evt, true
+	}
+
 	compacted := make([]map[string]interface{}, 0, len(events)/2)
+	compactStreamingEventsIter(next, func(evt map[string]interface{}) {
+		compacted = append(compacted, evt)
+	})
+	return compacted
+}
 
+// compactStreamingEventsIter is the streaming core of compactStreamingEvents.
+// It pulls events one at a time from next and pushes compacted events
+// one at a time to emit, so a caller driving it directly from a
+// streaming source (see loadEventsIter) never materializes the full
+// event history — only the currently-open (unended) TEXT_MESSAGE/
+// TOOL_CALL sequences are buffered at any point.
+func compactStreamingEventsIter(next func() (map[string]interface{}, bool), emit func(map[string]interface{})) {
 	textByID := make(map[string]*pendingText)
 	var textOrder []string
 	toolByID := make(map[string]*pendingTool)
@@ -248,23 +1212,25 @@ func compactStreamingEvents(events []map[string]interface{}) []map[string]interf
 			return
 		}
 		if p.start != nil {
-			compacted = append(compacted, p.start)
+			emit(p.start)
 		}
 		if len(p.deltas) > 0 {
 			combined := ""
 			for _, d := range p.deltas {
 				combined += d
 			}
-			compacted = append(compacted, map[string]interface{}{
+			emit(map[string]interface{}{
 				"type":      types.EventTypeTextMessageContent,
 				"messageId": id,
 				"delta":     combined,
 			})
 		}
 		if p.end != nil {
-			compacted = append(compacted, p.end)
+			emit(p.end)
+		}
+		for _, oe := range p.otherEvents {
+			emit(oe)
 		}
-		compacted = append(compacted, p.otherEvents...)
 		delete(textByID, id)
 	}
 
@@ -274,68 +1240,74 @@ func compactStreamingEvents(events []map[string]interface{}) []map[string]interf
 			return
 		}
 		if p.start != nil {
-			compacted = append(compacted, p.start)
+			emit(p.start)
 		}
 		if len(p.deltas) > 0 {
 			combined := ""
 			for _, d := range p.deltas {
 				combined += d
 			}
-			compacted = append(compacted, map[string]interface{}{
+			emit(map[string]interface{}{
 				"type":       types.EventTypeToolCallArgs,
 				"toolCallId": id,
 				"delta":      combined,
 			})
 		}
 		if p.end != nil {
-			compacted = append(compacted, p.end)
+			emit(p.end)
+		}
+		for _, oe := range p.otherEvents {
+			emit(oe)
 		}
-		compacted = append(compacted, p.otherEvents...)
 		delete(toolByID, id)
 	}
 
-	for _, evt := range events {
+	for {
+		evt, ok := next()
+		if !ok {
+			break
+		}
 		eventType, _ := evt["type"].(string)
 		switch eventType {
 		case types.EventTypeTextMessageStart:
 			if id, _ := evt["messageId"].(string); id != "" {
 				getText(id).start = evt
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		case types.EventTypeTextMessageContent:
 			if id, _ := evt["messageId"].(string); id != "" {
 				delta, _ := evt["delta"].(string)
 				getText(id).deltas = append(getText(id).deltas, delta)
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		case types.EventTypeTextMessageEnd:
 			if id, _ := evt["messageId"].(string); id != "" {
 				getText(id).end = evt
 				flushText(id)
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		case types.EventTypeToolCallStart:
 			if id, _ := evt["toolCallId"].(string); id != "" {
 				getTool(id).start = evt
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		case types.EventTypeToolCallArgs:
 			if id, _ := evt["toolCallId"].(string); id != "" {
 				delta, _ := evt["delta"].(string)
 				getTool(id).deltas = append(getTool(id).deltas, delta)
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		case types.EventTypeToolCallEnd:
 			if id, _ := evt["toolCallId"].(string); id != "" {
 				getTool(id).end = evt
 				flushTool(id)
 			} else {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		default:
 			// Buffer "other" events into ALL currently open (incomplete)
@@ -355,7 +1327,7 @@ func compactStreamingEvents(events []map[string]interface{}) []map[string]interf
 				}
 			}
 			if !buffered {
-				compacted = append(compacted, evt)
+				emit(evt)
 			}
 		}
 	}
@@ -371,8 +1343,195 @@ func compactStreamingEvents(events []map[string]interface{}) []map[string]interf
 			flushTool(id)
 		}
 	}
+}
 
-	return compacted
+// compactStreamingEventsIterWithIDs is compactStreamingEventsIter's
+// cursor-aware sibling, used when a compacted replay must still support
+// Last-Event-ID resume (see HandleAGUIEvents's finished-run branch).
+// Each raw event's cursor is tracked per pending sequence as it's
+// buffered, and a flushed group (start/combined-delta/end) is emitted
+// carrying the cursor of the LAST raw event it subsumes — so a client
+// resuming against a compacted replay only re-sees events at or after
+// its Last-Event-ID cursor, same as the raw-replay branch.
+func compactStreamingEventsIterWithIDs(
+	next func() (map[string]interface{}, eventCursor, bool),
+	emit func(map[string]interface{}, eventCursor),
+) {
+	textByID := make(map[string]*pendingText)
+	var textOrder []string
+	textCursor := make(map[string]eventCursor)
+	toolByID := make(map[string]*pendingTool)
+	var toolOrder []string
+	toolCursor := make(map[string]eventCursor)
+
+	getText := func(id string) *pendingText {
+		if p, ok := textByID[id]; ok {
+			return p
+		}
+		p := &pendingText{}
+		textByID[id] = p
+		textOrder = append(textOrder, id)
+		return p
+	}
+	getTool := func(id string) *pendingTool {
+		if p, ok := toolByID[id]; ok {
+			return p
+		}
+		p := &pendingTool{}
+		toolByID[id] = p
+		toolOrder = append(toolOrder, id)
+		return p
+	}
+
+	flushText := func(id string) {
+		p := textByID[id]
+		if p == nil {
+			return
+		}
+		cursor := textCursor[id]
+		if p.start != nil {
+			emit(p.start, cursor)
+		}
+		if len(p.deltas) > 0 {
+			combined := ""
+			for _, d := range p.deltas {
+				combined += d
+			}
+			emit(map[string]interface{}{
+				"type":      types.EventTypeTextMessageContent,
+				"messageId": id,
+				"delta":     combined,
+			}, cursor)
+		}
+		if p.end != nil {
+			emit(p.end, cursor)
+		}
+		for _, oe := range p.otherEvents {
+			emit(oe, cursor)
+		}
+		delete(textByID, id)
+		delete(textCursor, id)
+	}
+
+	flushTool := func(id string) {
+		p := toolByID[id]
+		if p == nil {
+			return
+		}
+		cursor := toolCursor[id]
+		if p.start != nil {
+			emit(p.start, cursor)
+		}
+		if len(p.deltas) > 0 {
+			combined := ""
+			for _, d := range p.deltas {
+				combined += d
+			}
+			emit(map[string]interface{}{
+				"type":       types.EventTypeToolCallArgs,
+				"toolCallId": id,
+				"delta":      combined,
+			}, cursor)
+		}
+		if p.end != nil {
+			emit(p.end, cursor)
+		}
+		for _, oe := range p.otherEvents {
+			emit(oe, cursor)
+		}
+		delete(toolByID, id)
+		delete(toolCursor, id)
+	}
+
+	for {
+		evt, cursor, ok := next()
+		if !ok {
+			break
+		}
+		eventType, _ := evt["type"].(string)
+		switch eventType {
+		case types.EventTypeTextMessageStart:
+			if id, _ := evt["messageId"].(string); id != "" {
+				getText(id).start = evt
+				textCursor[id] = cursor
+			} else {
+				emit(evt, cursor)
+			}
+		case types.EventTypeTextMessageContent:
+			if id, _ := evt["messageId"].(string); id != "" {
+				delta, _ := evt["delta"].(string)
+				getText(id).deltas = append(getText(id).deltas, delta)
+				textCursor[id] = cursor
+			} else {
+				emit(evt, cursor)
+			}
+		case types.EventTypeTextMessageEnd:
+			if id, _ := evt["messageId"].(string); id != "" {
+				getText(id).end = evt
+				textCursor[id] = cursor
+				flushText(id)
+			} else {
+				emit(evt, cursor)
+			}
+		case types.EventTypeToolCallStart:
+			if id, _ := evt["toolCallId"].(string); id != "" {
+				getTool(id).start = evt
+				toolCursor[id] = cursor
+			} else {
+				emit(evt, cursor)
+			}
+		case types.EventTypeToolCallArgs:
+			if id, _ := evt["toolCallId"].(string); id != "" {
+				delta, _ := evt["delta"].(string)
+				getTool(id).deltas = append(getTool(id).deltas, delta)
+				toolCursor[id] = cursor
+			} else {
+				emit(evt, cursor)
+			}
+		case types.EventTypeToolCallEnd:
+			if id, _ := evt["toolCallId"].(string); id != "" {
+				getTool(id).end = evt
+				toolCursor[id] = cursor
+				flushTool(id)
+			} else {
+				emit(evt, cursor)
+			}
+		default:
+			// Buffer "other" events into ALL currently open (incomplete)
+			// sequences so they replay in the correct position after
+			// compaction.  If no sequences are open, emit directly.
+			buffered := false
+			for _, id := range textOrder {
+				if p := textByID[id]; p != nil && p.start != nil && p.end == nil {
+					p.otherEvents = append(p.otherEvents, evt)
+					textCursor[id] = cursor
+					buffered = true
+				}
+			}
+			for _, id := range toolOrder {
+				if p := toolByID[id]; p != nil && p.start != nil && p.end == nil {
+					p.otherEvents = append(p.otherEvents, evt)
+					toolCursor[id] = cursor
+					buffered = true
+				}
+			}
+			if !buffered {
+				emit(evt, cursor)
+			}
+		}
+	}
+
+	// Flush incomplete sequences (mid-run reconnect)
+	for _, id := range textOrder {
+		if textByID[id] != nil {
+			flushText(id)
+		}
+	}
+	for _, id := range toolOrder {
+		if toolByID[id] != nil {
+			flushTool(id)
+		}
+	}
 }
 
 // ─── Timestamp sanitization ──────────────────────────────────────────
@@ -416,6 +1575,15 @@ func sanitizeEventTimestamp(evt map[string]interface{}) {
 // writeSSEEvent marshals an event and writes it in SSE data: format.
 // If the event is a map, timestamps are sanitized to epoch ms first.
 func writeSSEEvent(w http.ResponseWriter, event interface{}) {
+	writeSSEEventWithID(w, event, "")
+}
+
+// writeSSEEventWithID writes an event with a leading SSE "id:" field
+// so standard SSE clients (EventSource) track it for Last-Event-ID on
+// their next reconnect. id is omitted (and "data:" emitted alone) when
+// empty — e.g. events replayed from AGUI_SINGLE_WRITER_COMPAT storage,
+// which carries no per-event cursor.
+func writeSSEEventWithID(w http.ResponseWriter, event interface{}, id string) {
 	// Sanitize timestamps on map events (replayed from store)
 	if m, ok := event.(map[string]interface{}); ok {
 		sanitizeEventTimestamp(m)
@@ -425,12 +1593,27 @@ func writeSSEEvent(w http.ResponseWriter, event interface{}) {
 		log.Printf("AGUI Store: failed to marshal SSE event: %v", err)
 		return
 	}
-	fmt.Fprintf(w, "data: %s\n\n", data)
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\ndata: %s\n\n", id, data)
+	} else {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
 }
 
+// sseDataLine formats a raw "id: ...\ndata: ...\n\n" SSE frame for the
+// live broadcast pipe (publishLine), so every GET /agui/events
+// subscriber — not just the one driving the persist call — sees the
+// event's cursor for Last-Event-ID tracking. id is omitted when empty.
+func sseDataLine(id string, data []byte) string {
+	if id == "" {
+		return fmt.Sprintf("data: %s\n\n", data)
+	}
+	return fmt.Sprintf("id: %s\ndata: %s\n\n", id, data)
+}
+
 // ─── File helpers ────────────────────────────────────────────────────
 
 func ensureDir(path string) error {
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"ambient-code-backend/types"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// withStateBaseDir points StateBaseDir at a fresh temp directory for the
+// duration of fn, restoring the previous value afterward — same pattern
+// as withTrustedProxies in agui_clientip_test.go.
+func withStateBaseDir(t *testing.T, fn func()) {
+	t.Helper()
+	prev := StateBaseDir
+	StateBaseDir = t.TempDir()
+	defer func() { StateBaseDir = prev }()
+	fn()
+}
+
+// writeNamedWriterFile writes recs as a writer-file under sessionDir
+// named after an arbitrary writer ID, matching writerLogGlob — used to
+// simulate multiple writers without depending on the process-wide
+// writerID global.
+func writeNamedWriterFile(t *testing.T, sessionID, writer string, recs []storedEvent) {
+	t.Helper()
+	if err := ensureDir(sessionDir(sessionID)); err != nil {
+		t.Fatalf("ensureDir: %v", err)
+	}
+	var buf []byte
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+	path := sessionDir(sessionID) + "/agui-events." + writer + ".jsonl"
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// writeOwnWriterFile writes recs to this process's own writer file
+// (writerEventLogPath) — the file compactWriterFileInPlace reads and
+// rewrites.
+func writeOwnWriterFile(t *testing.T, sessionID string, recs []storedEvent) {
+	t.Helper()
+	if err := ensureDir(sessionDir(sessionID)); err != nil {
+		t.Fatalf("ensureDir: %v", err)
+	}
+	var buf []byte
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+	if err := os.WriteFile(writerEventLogPath(sessionID), buf, 0644); err != nil {
+		t.Fatalf("write writer file: %v", err)
+	}
+}
+
+func TestLoadRecords_MergeOrderingAcrossWriters(t *testing.T) {
+	withStateBaseDir(t, func() {
+		sessionID := "merge-order"
+
+		// writerA and writerB both have a record at TS=1000 — the merge
+		// must fall back to the (writerID, seq) tiebreak rather than
+		// leaving colliding-timestamp records in file-read order.
+		writeNamedWriterFile(t, sessionID, "writerA", []storedEvent{
+			{WriterID: "writerA", Seq: 1, TS: 1000, Event: map[string]interface{}{"type": "A1"}},
+			{WriterID: "writerA", Seq: 2, TS: 1000, Event: map[string]interface{}{"type": "A2"}},
+		})
+		writeNamedWriterFile(t, sessionID, "writerB", []storedEvent{
+			{WriterID: "writerB", Seq: 1, TS: 1000, Event: map[string]interface{}{"type": "B1"}},
+		})
+
+		records := loadRecords(sessionID)
+		if len(records) != 3 {
+			t.Fatalf("expected 3 merged records, got %d", len(records))
+		}
+		want := []eventCursor{
+			{WriterID: "writerA", Seq: 1},
+			{WriterID: "writerA", Seq: 2},
+			{WriterID: "writerB", Seq: 1},
+		}
+		for i, w := range want {
+			got := eventCursor{WriterID: records[i].WriterID, Seq: records[i].Seq}
+			if got != w {
+				t.Fatalf("record %d = %v, want %v", i, got, w)
+			}
+		}
+	})
+}
+
+func TestResumeIndex(t *testing.T) {
+	ids := []eventCursor{
+		{WriterID: "w1", Seq: 1},
+		{WriterID: "w1", Seq: 2},
+		{WriterID: "w1", Seq: 3},
+		{WriterID: "w1", Seq: 4},
+		{WriterID: "w1", Seq: 5},
+		{WriterID: "w1", Seq: 6},
+	}
+
+	if got := resumeIndex("", ids); got != 0 {
+		t.Fatalf("empty lastEventID: got %d, want 0", got)
+	}
+	if got := resumeIndex("not-a-cursor", ids); got != 0 {
+		t.Fatalf("malformed cursor: got %d, want 0", got)
+	}
+	if got := resumeIndex("w1:99", ids); got != 0 {
+		// Cursor not present — e.g. a raw delta's cursor folded away by
+		// compactWriterFileInPlace — must fall back to a full replay, not
+		// skip everything or panic.
+		t.Fatalf("cursor not found: got %d, want 0 (full replay fallback)", got)
+	}
+	if got := resumeIndex("w1:6", ids); got != 3 {
+		// Found at index 5: backs off by resumeResendWindow (3).
+		t.Fatalf("cursor found near end: got %d, want 3", got)
+	}
+	if got := resumeIndex("w1:1", ids); got != 0 {
+		// Found at index 0: backing off by resumeResendWindow would go
+		// negative, so it clamps to 0 rather than returning -2.
+		t.Fatalf("cursor found near start: got %d, want 0", got)
+	}
+}
+
+func TestCompactWriterFileInPlace_PreservesCursors(t *testing.T) {
+	withStateBaseDir(t, func() {
+		sessionID := "compact-cursors"
+
+		// Two back-to-back streamed text messages, stored as raw deltas —
+		// the shape compactWriterFileInPlace rewrites on RUN_FINISHED.
+		recs := []storedEvent{
+			{WriterID: "w1", Seq: 1, TS: 1000, Event: map[string]interface{}{"type": types.EventTypeTextMessageStart, "messageId": "m1"}},
+			{WriterID: "w1", Seq: 2, TS: 1001, Event: map[string]interface{}{"type": types.EventTypeTextMessageContent, "messageId": "m1", "delta": "hello "}},
+			{WriterID: "w1", Seq: 3, TS: 1002, Event: map[string]interface{}{"type": types.EventTypeTextMessageContent, "messageId": "m1", "delta": "world"}},
+			{WriterID: "w1", Seq: 4, TS: 1003, Event: map[string]interface{}{"type": types.EventTypeTextMessageEnd, "messageId": "m1"}},
+			{WriterID: "w1", Seq: 5, TS: 1004, Event: map[string]interface{}{"type": types.EventTypeTextMessageStart, "messageId": "m2"}},
+			{WriterID: "w1", Seq: 6, TS: 1005, Event: map[string]interface{}{"type": types.EventTypeTextMessageEnd, "messageId": "m2"}},
+		}
+		writeOwnWriterFile(t, sessionID, recs)
+
+		// A client reconnecting right after m1 finished would have been
+		// handed this cursor as the Last-Event-ID for m1's END event.
+		preCompactionCursor := eventCursor{WriterID: "w1", Seq: 4}
+
+		compactWriterFileInPlace(sessionID)
+
+		_, ids := loadEventsWithIDs(sessionID)
+		for _, id := range ids {
+			if id == preCompactionCursor {
+				return
+			}
+		}
+		t.Fatalf("cursor %v handed out before compaction no longer resolves after compaction; ids=%v", preCompactionCursor, ids)
+	})
+}
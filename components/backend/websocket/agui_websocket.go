@@ -0,0 +1,355 @@
+// agui_websocket.go — AG-UI protocol over a duplex WebSocket connection.
+//
+// This is an alternate client-facing transport for the same semantics
+// HandleAGUIEvents/HandleAGUIRunProxy/HandleAGUIInterrupt/HandleAGUIFeedback
+// expose over SSE + POST: persisted history replay, live event tailing,
+// and run/interrupt/feedback requests — all multiplexed over one socket
+// instead of one GET and three POSTs. Event persistence, compaction, and
+// runner proxying are unchanged; only how the client reaches them differs.
+package websocket
+
+import (
+	"ambient-code-backend/handlers"
+	"ambient-code-backend/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// aguiUpgrader upgrades HTTP connections to WebSocket. Origin checking is
+// intentionally permissive — access control happens via the same
+// SelfSubjectAccessReview (checkAccess) used by the SSE/HTTP handlers,
+// not by Origin header.
+var aguiUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+const (
+	wsPingInterval = 15 * time.Second // mirrors the SSE heartbeat interval
+	wsPongWait     = 60 * time.Second
+)
+
+// wsFrame is the envelope for both directions of the socket.
+//
+//	client → server: {"type":"run"|"interrupt"|"feedback","payload":...}
+//	server → client: {"type":"event"|"error"|"run_ack"|"interrupt_ack"|"feedback_ack","payload":...}
+type wsFrame struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// HandleAGUIWebSocket upgrades the connection and serves the same event
+// stream as HandleAGUIEvents, while also accepting "run", "interrupt" and
+// "feedback" frames from the client over the same connection in place of
+// POST /agui/run, /agui/interrupt and /agui/feedback.
+//
+// Reuses subscribeLive, loadEvents(Iter)/compactStreamingEvents(Iter) and
+// persistEvent so SSE and WebSocket subscribers of a session observe
+// identical history and live events. Runner proxying still goes through
+// proxyRunnerStream — only this client-facing transport is new.
+//
+// Wrapped with the long-running in-flight limiter, same bucket as
+// HandleAGUIEvents — see agui_limiter.go.
+var HandleAGUIWebSocket = WithAGUILongLimit(handleAGUIWebSocketImpl)
+
+func handleAGUIWebSocketImpl(c *gin.Context) {
+	projectName := c.Param("projectName")
+	sessionName := c.Param("sessionName")
+
+	reqK8s, _ := handlers.GetK8sClientsForRequest(c)
+	if reqK8s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing token"})
+		c.Abort()
+		return
+	}
+	if !checkAccess(reqK8s, projectName, sessionName, "get") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		c.Abort()
+		return
+	}
+
+	reqID := newRequestID()
+
+	// The upgrade handshake response is written directly over the
+	// hijacked connection, bypassing gin's ResponseWriter — so
+	// X-Request-Id has to be passed as Upgrade's response header param
+	// rather than set via c.Header.
+	conn, err := aguiUpgrader.Upgrade(c.Writer, c.Request, http.Header{"X-Request-Id": []string{reqID}})
+	if err != nil {
+		aguiLog.Error("AGUI WebSocket: upgrade failed", "reqId", reqID, "project", projectName, "session", sessionName, "err", err)
+		return
+	}
+
+	actor := actorFromHTTPRequest(c.Request)
+	aguiLog.Info("AGUI WebSocket: client connected", "reqId", reqID, "project", projectName, "session", sessionName, "actor", actor.String())
+
+	// Optional resume cursor — WebSocket clients have no Last-Event-ID
+	// header equivalent, so this is taken from the upgrade request's
+	// query string instead: ?lastEventId=<writerId>:<seq>
+	lastEventID := c.Query("lastEventId")
+
+	// All writes to conn must come from a single goroutine (gorilla
+	// websocket connections are not safe for concurrent writers), so
+	// every outbound frame — replay, live tail, pings, and frame-request
+	// acks — funnels through wsWriteLoop via outCh.
+	outCh := make(chan wsFrame, 64)
+	var writeWg sync.WaitGroup
+	writeWg.Add(1)
+	go func() {
+		defer writeWg.Done()
+		wsWriteLoop(conn, outCh)
+	}()
+
+	// readDone is closed once the read pump below has returned. outCh must
+	// not be closed while the read pump could still be blocked inside
+	// handleWSClientFrame, which sends on it — so conn.Close() (which
+	// unblocks the read pump's conn.ReadMessage call) has to happen, and
+	// readDone has to be observed, before outCh is closed. Getting this
+	// order backwards lets an in-flight client frame send on a closed
+	// channel and panic.
+	readDone := make(chan struct{})
+	defer func() {
+		conn.Close()
+		<-readDone
+		close(outCh)
+		writeWg.Wait()
+	}()
+
+	// Subscribe to live broadcast pipe BEFORE loading persisted events —
+	// same ordering rationale as HandleAGUIEvents.
+	liveCh, cleanup := subscribeLive(sessionName)
+	defer cleanup()
+
+	if last := lastEvent(sessionName); last != nil {
+		runFinished := false
+		if t, _ := last["type"].(string); t == types.EventTypeRunFinished {
+			runFinished = true
+		}
+
+		if runFinished {
+			// See HandleAGUIEvents's finished-run branch: the ID-aware
+			// compaction variant carries a cursor forward through each
+			// compacted group, so Last-Event-ID resume still works here.
+			// If the client's cursor is never matched, fall back to a
+			// full replay of what was skipped rather than sending
+			// nothing.
+			resumeCursor, hasResume := parseEventCursor(lastEventID)
+			passedResume := !hasResume
+			var skipped []pendingReplayCursorEvent
+			next, closeIter := loadEventsIterWithIDs(sessionName)
+			compactStreamingEventsIterWithIDs(next, func(evt map[string]interface{}, cursor eventCursor) {
+				if !passedResume {
+					if cursor == resumeCursor {
+						passedResume = true
+					} else {
+						skipped = append(skipped, pendingReplayCursorEvent{evt: evt, cursor: cursor})
+					}
+					return
+				}
+				sendWSEvent(outCh, evt)
+			})
+			closeIter()
+			if !passedResume {
+				aguiLog.Info("AGUI WebSocket: resume cursor not found, falling back to full replay", "reqId", reqID, "session", sessionName)
+				for _, p := range skipped {
+					sendWSEvent(outCh, p.evt)
+				}
+			}
+		} else {
+			events, ids := loadEventsWithIDs(sessionName)
+			startIdx := resumeIndex(lastEventID, ids)
+			if startIdx > 0 {
+				for _, evt := range openSequencesBeforeCursor(events[:startIdx]) {
+					sendWSEvent(outCh, evt)
+				}
+			}
+			for i := startIdx; i < len(events); i++ {
+				sendWSEvent(outCh, events[i])
+			}
+		}
+	}
+
+	// Live events buffered during replay are already covered above.
+	drainLiveChannel(liveCh)
+
+	// Read pump: runs in its own goroutine, blocking on conn.ReadMessage.
+	// Client frame handling (run/interrupt/feedback) is synchronous, same
+	// as the equivalent HTTP handlers — the ack is queued once the runner
+	// request (or persist) completes.
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	go func() {
+		defer close(readDone)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame wsFrame
+			if err := json.Unmarshal(data, &frame); err != nil {
+				aguiLog.Warn("AGUI WebSocket: bad frame", "reqId", reqID, "session", sessionName, "err", err)
+				continue
+			}
+			handleWSClientFrame(c.Request.Context(), projectName, sessionName, frame, actor, outCh)
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	clientGone := c.Request.Context().Done()
+	for {
+		select {
+		case <-clientGone:
+			aguiLog.Info("AGUI WebSocket: request context done", "reqId", reqID, "session", sessionName)
+			return
+		case <-readDone:
+			aguiLog.Info("AGUI WebSocket: client disconnected", "reqId", reqID, "session", sessionName)
+			return
+		case line, ok := <-liveCh:
+			if !ok {
+				return
+			}
+			if data, _, ok := parseSSEDataLine(line); ok {
+				select {
+				case outCh <- wsFrame{Type: "event", Payload: json.RawMessage(data)}:
+				case <-readDone:
+					return
+				}
+			}
+		case <-ping.C:
+			select {
+			case outCh <- wsFrame{Type: "ping"}:
+			case <-readDone:
+				return
+			}
+		}
+	}
+}
+
+// wsWriteLoop is the sole writer of conn; it serializes replay frames,
+// live-tail frames, frame-request acks, and ping keepalives so the
+// connection never sees concurrent writes. On a write error it closes
+// conn itself — on a half-open connection (writes failing, reads
+// hanging) that's what unblocks the read pump's conn.ReadMessage, which
+// in turn closes readDone so the main loop's guarded outCh sends and the
+// handler's deferred cleanup aren't left waiting on a writer that's
+// already gone.
+func wsWriteLoop(conn *websocket.Conn, outCh <-chan wsFrame) {
+	for frame := range outCh {
+		if frame.Type == "ping" {
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.Close()
+				return
+			}
+			continue
+		}
+		if err := conn.WriteJSON(frame); err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// sendWSEvent wraps a persisted/compacted event as an "event" frame.
+func sendWSEvent(outCh chan<- wsFrame, evt map[string]interface{}) {
+	sanitizeEventTimestamp(evt)
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		aguiLog.Error("AGUI WebSocket: failed to marshal event", "err", err)
+		return
+	}
+	outCh <- wsFrame{Type: "event", Payload: payload}
+}
+
+// parseSSEDataLine extracts the id and JSON payload from a pre-formatted
+// SSE frame produced by sseDataLine ("id: ...\ndata: ...\n\n"), so the
+// live broadcast pipe — built for the SSE transport — can be replayed
+// as WebSocket frames without duplicating runner-stream plumbing.
+// Non-data lines (blank separators, ": heartbeat" comments) are ignored;
+// the WebSocket transport uses ping/pong for keepalive instead.
+func parseSSEDataLine(line string) (data []byte, id string, ok bool) {
+	for _, part := range strings.Split(strings.TrimRight(line, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(part, "id: "):
+			id = strings.TrimPrefix(part, "id: ")
+		case strings.HasPrefix(part, "data: "):
+			data = []byte(strings.TrimPrefix(part, "data: "))
+		}
+	}
+	return data, id, data != nil
+}
+
+// handleWSClientFrame dispatches a client→server frame to the same
+// business logic the HTTP handlers use, and queues the result as an
+// outbound frame. Each frame gets its own request ID — like a discrete
+// POST /agui/run, /agui/interrupt or /agui/feedback call would — echoed
+// back in the ack/error frame so the client can correlate it with backend
+// (and runner) logs.
+func handleWSClientFrame(ctx context.Context, projectName, sessionName string, frame wsFrame, actor aguiActor, outCh chan<- wsFrame) {
+	reqID := newRequestID()
+
+	switch frame.Type {
+	case "run":
+		var input types.RunAgentInput
+		if err := json.Unmarshal(frame.Payload, &input); err != nil {
+			sendWSError(outCh, "run", "invalid run payload: "+err.Error(), reqID)
+			return
+		}
+		threadID, runID, err := startAGUIRun(projectName, sessionName, input, actor, reqID)
+		if err != nil {
+			sendWSError(outCh, "run", err.Error(), reqID)
+			return
+		}
+		ack, _ := json.Marshal(gin.H{"runId": runID, "threadId": threadID, "requestId": reqID})
+		outCh <- wsFrame{Type: "run_ack", Payload: ack}
+
+	case "interrupt":
+		if err := sendInterruptToRunner(ctx, projectName, sessionName, reqID); err != nil {
+			sendWSError(outCh, "interrupt", err.Error(), reqID)
+			return
+		}
+		ack, _ := json.Marshal(gin.H{"message": "Interrupt signal sent", "requestId": reqID})
+		outCh <- wsFrame{Type: "interrupt_ack", Payload: ack}
+
+	case "feedback":
+		var metaEvent map[string]interface{}
+		if err := json.Unmarshal(frame.Payload, &metaEvent); err != nil {
+			sendWSError(outCh, "feedback", "invalid feedback payload: "+err.Error(), reqID)
+			return
+		}
+		if eventType, _ := metaEvent["type"].(string); eventType != types.EventTypeMeta {
+			sendWSError(outCh, "feedback", "Expected META event type", reqID)
+			return
+		}
+		message, status, err := sendFeedbackToRunner(ctx, projectName, sessionName, metaEvent, reqID)
+		if err != nil {
+			sendWSError(outCh, "feedback", err.Error(), reqID)
+			return
+		}
+		ack, _ := json.Marshal(gin.H{"message": message, "status": status, "requestId": reqID})
+		outCh <- wsFrame{Type: "feedback_ack", Payload: ack}
+
+	default:
+		aguiLog.Warn("AGUI WebSocket: unknown frame type", "reqId", reqID, "type", frame.Type, "session", sessionName)
+	}
+}
+
+// sendWSError queues an "error" frame naming the client frame type that
+// failed, so the client can correlate it with the request it sent.
+func sendWSError(outCh chan<- wsFrame, forType, message, reqID string) {
+	payload, _ := json.Marshal(gin.H{"for": forType, "error": message, "requestId": reqID})
+	outCh <- wsFrame{Type: "error", Payload: payload}
+}